@@ -0,0 +1,163 @@
+package memdb
+
+type ChangeKind int
+
+const (
+	ChangeInsert ChangeKind = iota
+	ChangeUpdate
+	ChangeDelete
+)
+
+// Change describes modification of a single table entry. Prev holds
+// the value replaced by an update or removed by a delete, and Value
+// holds the value set by an insert or an update.
+type Change struct {
+	Kind  ChangeKind
+	Key   []byte
+	Prev  interface{}
+	Value interface{}
+}
+
+// ChangeSet lists changes published by a single commit.
+type ChangeSet struct {
+	Version uint64
+	tables  map[interface{}][]Change
+}
+
+// Changes returns changes made to given table, sorted by primary key.
+func (cs *ChangeSet) Changes(t TableType) []Change {
+	return cs.tables[t.tableRef()]
+}
+
+// Len returns the number of changes in the set.
+func (cs *ChangeSet) Len() int {
+	n := 0
+	for _, changes := range cs.tables {
+		n += len(changes)
+	}
+	return n
+}
+
+// TableChange is a Change of an entry of Table[V] with typed values.
+type TableChange[V any] struct {
+	Kind  ChangeKind
+	Key   []byte
+	Prev  V
+	Value V
+}
+
+// Changes returns the net changes made by the transaction so far. The
+// version of the returned set is zero until the transaction commits.
+func (tx *Txn) Changes() *ChangeSet {
+	cs := &ChangeSet{
+		Version: tx.version,
+		tables:  make(map[interface{}][]Change, len(tx.changes)),
+	}
+	if cs.Version == tx.base {
+		cs.Version = 0
+	}
+	for ref, changes := range tx.changes {
+		cs.tables[ref] = exportChanges(changes.commit())
+	}
+	return cs
+}
+
+// Changes returns the net changes made to the table by the transaction
+// so far, sorted by primary key.
+func (t Table[V]) Changes(tx *Txn) []TableChange[V] {
+	changes, ok := tx.changes[t.ref]
+	if !ok {
+		return nil
+	}
+	out := []TableChange[V]{}
+	for _, c := range exportChanges(changes.commit()) {
+		tc := TableChange[V]{Kind: c.Kind, Key: c.Key}
+		if c.Prev != nil {
+			tc.Prev = c.Prev.(V)
+		}
+		if c.Value != nil {
+			tc.Value = c.Value.(V)
+		}
+		out = append(out, tc)
+	}
+	return out
+}
+
+// change describes the net modification of a single entry made by
+// a transaction. Changes are immutable once stored in a tree.
+type change struct {
+	prev    interface{}
+	value   interface{}
+	existed bool
+	exists  bool
+}
+
+// track records the modification of the entry under key k in given
+// table, merging it with the changes made before by the transaction.
+func (tx *Txn) track(ref interface{}, k []byte, prev interface{}, existed bool, v interface{}, exists bool) {
+	if !tx.write {
+		return
+	}
+	changes, ok := tx.changes[ref]
+	if !ok {
+		changes = makeTree[*change]().txn(true)
+		tx.changes[ref] = changes
+	}
+	mergeChange(changes, k, &change{
+		prev:    prev,
+		value:   v,
+		existed: existed,
+		exists:  exists,
+	})
+	if changes.root == nil {
+		delete(tx.changes, ref)
+	}
+}
+
+// mergeChange merges the change of the entry under key k into the net
+// changes made before it.
+func mergeChange(changes *treeTxn[*change], k []byte, c *change) {
+	if old, ok := changes.get(k); ok {
+		c = &change{
+			prev:    old.prev,
+			value:   c.value,
+			existed: old.existed,
+			exists:  c.exists,
+		}
+	}
+	if !c.existed && !c.exists {
+		// entry created and deleted in between
+		changes.del(k)
+		return
+	}
+	changes.set(k, c)
+}
+
+func (c *change) export(k []byte) Change {
+	out := Change{Key: k}
+	switch {
+	case !c.existed:
+		out.Kind = ChangeInsert
+		out.Value = c.value
+	case !c.exists:
+		out.Kind = ChangeDelete
+		out.Prev = c.prev
+	default:
+		out.Kind = ChangeUpdate
+		out.Prev = c.prev
+		out.Value = c.value
+	}
+	return out
+}
+
+// exportChanges converts the tree of changes into a list.
+func exportChanges(changes *tree[*change]) []Change {
+	out := []Change{}
+	c := changes.txn(false).cursor()
+	ok := c.first()
+	for ok {
+		out = append(out, c.val().export(c.key()))
+		ok = c.next()
+	}
+	return out
+}
@@ -0,0 +1,278 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// Encoded change set layout, all integers big-endian:
+//
+//	size    uint32  size of the payload
+//	crc     uint32  Castagnoli checksum of the payload
+//	payload:
+//	    version uint64
+//	    tables  uvarint number of tables
+//	    for each table:
+//	        name    uvarint length followed by the name of the table
+//	        changes uvarint number of changes
+//	        for each change:
+//	            kind    byte, the ChangeKind
+//	            key     uvarint length followed by the primary key
+//	            value   uvarint length followed by the value encoded
+//	                    with the codec of the table, omitted for
+//	                    deletes
+const changeSetHeaderSize = 8
+
+// EncodeChangeSet encodes the change set using codecs of the tables.
+// Previous values of updated and deleted entries are not encoded.
+func (db *DB) EncodeChangeSet(cs *ChangeSet) ([]byte, error) {
+	b := make([]byte, changeSetHeaderSize, 256)
+	b = binary.BigEndian.AppendUint64(b, cs.Version)
+	n := 0
+	for _, t := range db.tables {
+		if _, ok := cs.tables[t.tableRef()]; ok {
+			n++
+		}
+	}
+	b = binary.AppendUvarint(b, uint64(n))
+	for _, t := range db.tables {
+		changes, ok := cs.tables[t.tableRef()]
+		if !ok {
+			continue
+		}
+		b = appendBytes(b, []byte(db.names[t.tableRef()]))
+		b = binary.AppendUvarint(b, uint64(len(changes)))
+		for _, c := range changes {
+			b = append(b, byte(c.Kind))
+			b = appendBytes(b, c.Key)
+			if c.Kind == ChangeDelete {
+				continue
+			}
+			data, err := t.marshal(c.Value)
+			if err != nil {
+				return nil, err
+			}
+			b = appendBytes(b, data)
+		}
+	}
+	payload := b[changeSetHeaderSize:]
+	binary.BigEndian.PutUint32(b[:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(payload, crcTable))
+	return b, nil
+}
+
+// encodeChanges encodes changes of a transaction committed under given
+// version.
+func (db *DB) encodeChanges(version uint64, changes map[interface{}]*treeTxn[*change]) ([]byte, error) {
+	cs := &ChangeSet{
+		Version: version,
+		tables:  make(map[interface{}][]Change, len(changes)),
+	}
+	for ref, c := range changes {
+		cs.tables[ref] = exportChanges(c.commit())
+	}
+	return db.EncodeChangeSet(cs)
+}
+
+// DecodeChangeSet decodes a change set encoded with EncodeChangeSet.
+func (db *DB) DecodeChangeSet(data []byte) (*ChangeSet, error) {
+	if len(data) < changeSetHeaderSize {
+		return nil, ErrInvalidChangeSet
+	}
+	payload := data[changeSetHeaderSize:]
+	if int(binary.BigEndian.Uint32(data[:4])) != len(payload) ||
+		crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(data[4:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidChangeSet)
+	}
+	refs := make(map[string]TableType, len(db.tables))
+	for _, t := range db.tables {
+		refs[db.names[t.tableRef()]] = t
+	}
+	r := &recordReader{b: payload}
+	cs := &ChangeSet{
+		Version: r.uint64(),
+		tables:  map[interface{}][]Change{},
+	}
+	tables := r.uvarint()
+	for i := uint64(0); i < tables && r.err == nil; i++ {
+		name := string(r.bytes())
+		t, ok := refs[name]
+		if r.err == nil && !ok {
+			return nil, fmt.Errorf("%w: unknown table %q", ErrInvalidChangeSet, name)
+		}
+		n := r.uvarint()
+		changes := []Change{}
+		for j := uint64(0); j < n && r.err == nil; j++ {
+			c := Change{Kind: ChangeKind(r.byte()), Key: r.bytes()}
+			if c.Kind == ChangeDelete || r.err != nil {
+				changes = append(changes, c)
+				continue
+			}
+			v, err := t.decode(r.bytes())
+			if r.err == nil && err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidChangeSet, err)
+			}
+			c.Value = v
+			changes = append(changes, c)
+		}
+		if r.err == nil {
+			cs.tables[t.tableRef()] = changes
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return cs, nil
+}
+
+type ApplyOptions struct {
+	// AllowGaps accepts change sets whose version does not directly
+	// follow the current version of the database, e.g. commands of a
+	// consensus log interleaved with other entries.
+	AllowGaps bool
+}
+
+// ApplyChangeSet commits changes of the change set. A change set with
+// a version is committed under the same version and is skipped if the
+// database includes it already, so change sets of another database
+// can be applied in order to keep a replica of it. A change set whose
+// version does not directly follow the current version of the database
+// fails with ErrVersionGap. Change sets without changes advance the
+// version as well. If the changes violate unique indexes or foreign
+// keys, nothing is changed and UniqueViolationError or ForeignKeyError
+// is returned. ApplyChangeSet works on read-only databases as well.
+func (db *DB) ApplyChangeSet(cs *ChangeSet) error {
+	return db.ApplyChangeSetWithOptions(cs, ApplyOptions{})
+}
+
+// ApplyChangeSetWithOptions is like ApplyChangeSet, with options
+// deciding which versions of change sets are accepted.
+func (db *DB) ApplyChangeSetWithOptions(cs *ChangeSet, opts ApplyOptions) error {
+	tx := db.writeTx()
+	defer tx.Abort()
+	for _, t := range db.tables {
+		ref := t.tableRef()
+		for _, c := range cs.tables[ref] {
+			ch := &change{value: c.Value, exists: c.Kind != ChangeDelete}
+			if err := db.replayfn[ref](tx, c.Key, ch); err != nil {
+				return err
+			}
+		}
+	}
+	// change sets built from independent snapshots, e.g. commands
+	// of a consensus log, may together violate constraints
+	if err := db.validate(tx); err != nil {
+		return err
+	}
+	tx.applied = cs.Version
+	if opts.AllowGaps {
+		tx.applyMode = applyGaps
+	}
+	// the version is checked under the commit lock, so a change set
+	// applied concurrently is committed only once
+	if err := tx.Commit(); err != errApplied {
+		return err
+	}
+	return nil
+}
+
+// applyMode decides the version a change set or a snapshot applied by
+// a transaction is published under.
+type applyMode int
+
+const (
+	// applyNext requires the version to follow the latest one.
+	applyNext applyMode = iota
+	// applyGaps accepts any version above the latest one.
+	applyGaps
+	// applyExact publishes under exactly the version, which may be
+	// lower than the latest one when a snapshot is loaded.
+	applyExact
+)
+
+// errApplied is returned by commits of change sets which the database
+// includes already.
+var errApplied = errors.New("memdb: change set is already applied")
+
+// publishVersion returns the version the commit of the transaction is
+// published under, given the latest root. It must be called with the
+// commit lock held.
+func (tx *Txn) publishVersion(head *dbRoot) (uint64, error) {
+	next := head.version + 1
+	v := tx.applied
+	switch {
+	case tx.applyMode == applyExact:
+		if v < next && tx.db.durable() {
+			// commits are replayed from the log by their versions,
+			// so they can not go back
+			return 0, ErrVersionRewind
+		}
+		return v, nil
+	case v == 0:
+		return next, nil
+	case v < next:
+		return 0, errApplied
+	case tx.applyMode == applyNext && v != next:
+		return 0, fmt.Errorf("%w: version %d follows %d", ErrVersionGap, v, head.version)
+	}
+	return v, nil
+}
+
+func appendBytes(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// recordReader decodes an encoded change set, keeping the first error.
+type recordReader struct {
+	b   []byte
+	err error
+}
+
+func (r *recordReader) take(n uint64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(len(r.b)) < n {
+		r.err = ErrInvalidChangeSet
+		return nil
+	}
+	out := r.b[:n]
+	r.b = r.b[n:]
+	return out
+}
+
+func (r *recordReader) byte() byte {
+	b := r.take(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *recordReader) uint64() uint64 {
+	b := r.take(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *recordReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = ErrInvalidChangeSet
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *recordReader) bytes() []byte {
+	return r.take(r.uvarint())
+}
@@ -0,0 +1,113 @@
+package memdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDB_ApplyChangeSet(t *testing.T) {
+	src, users := makeTestUserDB(t)
+	sub := src.WatchWithOptions(WatchOptions{Buffer: 10})
+	defer sub.Close()
+	tx := src.WriteTx()
+	_ = users.SetMulti(tx, makeTestUsers())
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx = src.WriteTx()
+	_ = users.Del(tx, IntKey(1))
+	_ = users.Set(tx, &testUser{ID: 2, Name: "Anna Smith"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, dstUsers := openTestUserDB(t, Options{ReadOnly: true})
+	tx = dst.WriteTx()
+	if err := dstUsers.Set(tx, &testUser{ID: 1}); err != ErrTxnReadOnly {
+		t.Errorf("Table.Set() on read-only database error = %v, want %v", err, ErrTxnReadOnly)
+	}
+	encoded := [][]byte{}
+	for i := 0; i < 2; i++ {
+		cs := <-sub.C()
+		data, err := src.EncodeChangeSet(cs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded = append(encoded, data)
+		decoded, err := dst.DecodeChangeSet(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Version != cs.Version {
+			t.Errorf("ChangeSet.Version = %v, want %v", decoded.Version, cs.Version)
+		}
+		if err := dst.ApplyChangeSet(decoded); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Version() != cs.Version {
+			t.Errorf("DB.Version() = %v, want %v", dst.Version(), cs.Version)
+		}
+	}
+	// applying a change set again does nothing
+	cs, _ := dst.DecodeChangeSet(encoded[0])
+	if err := dst.ApplyChangeSet(cs); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := users.Select(src.ReadTx()).All()
+	got, _ := dstUsers.Select(dst.ReadTx()).All()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("TableLister.All() of replica = %+v, want %+v", got, want)
+	}
+
+	corrupted := append([]byte{}, encoded[1]...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	if _, err := dst.DecodeChangeSet(corrupted); !errors.Is(err, ErrInvalidChangeSet) {
+		t.Errorf("DB.DecodeChangeSet() error = %v, want %v", err, ErrInvalidChangeSet)
+	}
+}
+
+func TestDB_ApplyChangeSet_versions(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	tx := db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 1})
+	cs := tx.Changes()
+	tx.Abort()
+
+	cs.Version = 3
+	if err := db.ApplyChangeSet(cs); !errors.Is(err, ErrVersionGap) {
+		t.Errorf("DB.ApplyChangeSet() error = %v, want %v", err, ErrVersionGap)
+	}
+	if db.Version() != 0 {
+		t.Errorf("DB.Version() = %v, want 0", db.Version())
+	}
+
+	// concurrent applies of the same change set commit it once
+	cs.Version = 1
+	errc := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			errc <- db.ApplyChangeSet(cs)
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if db.Version() != 1 {
+		t.Errorf("DB.Version() = %v, want 1", db.Version())
+	}
+
+	// change sets without changes advance the version
+	empty := &ChangeSet{Version: 5}
+	if err := db.ApplyChangeSetWithOptions(empty, ApplyOptions{AllowGaps: true}); err != nil {
+		t.Fatal(err)
+	}
+	if db.Version() != 5 {
+		t.Errorf("DB.Version() = %v, want 5", db.Version())
+	}
+	if n, _ := users.Select(db.ReadTx()).Count(); n != 1 {
+		t.Errorf("TableLister.Count() = %v, want 1", n)
+	}
+}
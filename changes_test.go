@@ -0,0 +1,56 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxn_Changes(t *testing.T) {
+	anna := &testUser{ID: 1, Name: "Anna"}
+	bob := &testUser{ID: 2, Name: "Bob"}
+	carl := &testUser{ID: 3, Name: "Carl"}
+	db, users := makeTestUserDB(t, anna, bob)
+	backup := makeTestUserTable()
+
+	tx := db.WriteTx()
+	if got := tx.Changes().Changes(users); got != nil {
+		t.Errorf("ChangeSet.Changes() = %+v, want nil", got)
+	}
+	anna2 := &testUser{ID: 1, Name: "Anna Smith"}
+	anna3 := &testUser{ID: 1, Name: "Anna Brown"}
+	dave := &testUser{ID: 4, Name: "Dave"}
+	_ = users.Set(tx, anna2)
+	_ = users.Set(tx, anna3)
+	_ = users.Del(tx, IntKey(2))
+	_ = users.Set(tx, carl)
+	_ = users.Set(tx, dave)
+	_ = users.Del(tx, IntKey(4))
+
+	want := []TableChange[*testUser]{
+		{Kind: ChangeUpdate, Key: IntKey(1).Bytes(), Prev: anna, Value: anna3},
+		{Kind: ChangeDelete, Key: IntKey(2).Bytes(), Prev: bob},
+		{Kind: ChangeInsert, Key: IntKey(3).Bytes(), Value: carl},
+	}
+	if got := users.Changes(tx); !reflect.DeepEqual(got, want) {
+		t.Errorf("Table.Changes() = %+v, want %+v", got, want)
+	}
+	if got := backup.Changes(tx); got != nil {
+		t.Errorf("Table.Changes() of unchanged table = %+v, want nil", got)
+	}
+	cs := tx.Changes()
+	if cs.Version != 0 {
+		t.Errorf("ChangeSet.Version = %v, want 0", cs.Version)
+	}
+	if got := cs.Changes(users); len(got) != len(want) {
+		t.Errorf("ChangeSet.Changes() = %+v, want %v changes", got, len(want))
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if cs := tx.Changes(); cs.Version != tx.Version() {
+		t.Errorf("ChangeSet.Version = %v, want %v", cs.Version, tx.Version())
+	}
+	if got := users.Changes(tx); !reflect.DeepEqual(got, want) {
+		t.Errorf("Table.Changes() after commit = %+v, want %+v", got, want)
+	}
+}
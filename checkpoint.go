@@ -0,0 +1,258 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultCheckpointKeep = 2
+
+type CheckpointOptions struct {
+	// Dir is the directory checkpoints are written to, created if it
+	// does not exist.
+	Dir string
+	// Keep is the number of the latest checkpoints kept in the
+	// directory. Zero means DefaultCheckpointKeep.
+	Keep int
+	// Commits is the number of commits after which a checkpoint is
+	// taken. Zero disables checkpoints by commit count.
+	Commits int
+	// Age is how long after the last checkpoint a new one is taken,
+	// if the database has changed since. Zero disables checkpoints by
+	// age.
+	Age time.Duration
+	// OnError is called with errors of checkpoints taken in the
+	// background.
+	OnError func(err error)
+}
+
+const (
+	checkpointPrefix = "checkpoint-"
+	checkpointExt    = ".memdb"
+)
+
+// checkpointer writes checkpoints of the database in the background.
+type checkpointer struct {
+	db      *DB
+	opts    CheckpointOptions
+	mu      sync.Mutex // serializes checkpoints
+	version uint64     // version of the last checkpoint
+	commits int64      // commits since the last checkpoint
+	kick    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+// openCheckpoints loads the newest valid checkpoint from the directory.
+// Checkpoints are taken in the background once start is called.
+func (db *DB) openCheckpoints(opts CheckpointOptions) error {
+	if opts.Keep <= 0 {
+		opts.Keep = DefaultCheckpointKeep
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return err
+	}
+	c := &checkpointer{
+		db:   db,
+		opts: opts,
+		kick: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	files, err := c.list()
+	if err != nil {
+		return err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		err := db.loadFile(filepath.Join(opts.Dir, files[i].name))
+		if errors.Is(err, ErrInvalidSnapshot) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.version = db.Version()
+		break
+	}
+	db.checkpoints = c
+	return nil
+}
+
+// start starts taking checkpoints in the background.
+func (c *checkpointer) start() {
+	c.wg.Add(1)
+	go c.run()
+}
+
+// Checkpoint writes a checkpoint of the database to the checkpoint
+// directory, unless the latest one includes all the commits already.
+func (db *DB) Checkpoint() error {
+	if db.checkpoints == nil {
+		return errors.New("memdb: checkpoints are not enabled")
+	}
+	return db.checkpoints.checkpoint()
+}
+
+// committed counts the commit towards the checkpoint threshold.
+func (c *checkpointer) committed() {
+	n := atomic.AddInt64(&c.commits, 1)
+	if c.opts.Commits > 0 && n >= int64(c.opts.Commits) {
+		select {
+		case c.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (c *checkpointer) run() {
+	defer c.wg.Done()
+	var tick <-chan time.Time
+	if c.opts.Age > 0 {
+		t := time.NewTicker(c.opts.Age)
+		defer t.Stop()
+		tick = t.C
+	}
+	for {
+		select {
+		case <-c.kick:
+		case <-tick:
+		case <-c.done:
+			return
+		}
+		if err := c.checkpoint(); err != nil && c.opts.OnError != nil {
+			c.opts.OnError(err)
+		}
+	}
+}
+
+// close stops the background checkpoints and takes the final one.
+func (c *checkpointer) close() error {
+	if !c.stop() {
+		return nil
+	}
+	return c.checkpoint()
+}
+
+// stop stops the background checkpoints without taking the final one,
+// and reports whether they were running.
+func (c *checkpointer) stop() bool {
+	stopped := false
+	c.once.Do(func() {
+		close(c.done)
+		c.wg.Wait()
+		stopped = true
+	})
+	return stopped
+}
+
+// checkpoint writes a checkpoint to a temporary file and renames it
+// into place, so a crash never leaves a partial checkpoint behind.
+func (c *checkpointer) checkpoint() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx := c.db.ReadTx()
+	if tx.Version() == c.version {
+		return nil
+	}
+	n := atomic.LoadInt64(&c.commits)
+	f, err := os.CreateTemp(c.opts.Dir, checkpointPrefix+"*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = c.db.SaveTx(tx, f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(c.opts.Dir, checkpointName(tx.Version())))
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(c.opts.Dir)
+	c.version = tx.Version()
+	atomic.AddInt64(&c.commits, -n)
+	if err := c.db.trimWAL(c.version); err != nil {
+		return err
+	}
+	return c.prune()
+}
+
+// prune removes all but the latest checkpoints, and temporary files
+// left by interrupted ones.
+func (c *checkpointer) prune() error {
+	files, err := c.list()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(files)-c.opts.Keep; i++ {
+		if err := os.Remove(filepath.Join(c.opts.Dir, files[i].name)); err != nil {
+			return err
+		}
+	}
+	tmps, err := filepath.Glob(filepath.Join(c.opts.Dir, checkpointPrefix+"*.tmp"))
+	if err != nil {
+		return err
+	}
+	for _, tmp := range tmps {
+		os.Remove(tmp)
+	}
+	return nil
+}
+
+type checkpointFile struct {
+	name    string
+	version uint64
+}
+
+// list returns checkpoints in the directory sorted by version.
+func (c *checkpointer) list() ([]checkpointFile, error) {
+	entries, err := os.ReadDir(c.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []checkpointFile{}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, checkpointPrefix) || !strings.HasSuffix(name, checkpointExt) {
+			continue
+		}
+		v, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, checkpointPrefix), checkpointExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		files = append(files, checkpointFile{name: name, version: v})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].version < files[j].version
+	})
+	return files, nil
+}
+
+func checkpointName(version uint64) string {
+	return fmt.Sprintf("%s%020d%s", checkpointPrefix, version, checkpointExt)
+}
+
+// syncDir flushes the directory entry of a renamed file to disk. It
+// is not supported on every platform, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	d.Close()
+}
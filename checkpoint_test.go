@@ -0,0 +1,205 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func listTestCheckpoints(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, checkpointPrefix+"*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, f := range files {
+		names = append(names, filepath.Base(f))
+	}
+	return names
+}
+
+func waitTestCheckpoint(t *testing.T, dir string, version uint64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := os.Stat(filepath.Join(dir, checkpointName(version))); err == nil {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("checkpoint of version %v not written, found %v", version, listTestCheckpoints(t, dir))
+}
+
+func TestDB_Checkpoint(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{Checkpoint: &CheckpointOptions{Dir: dir, Commits: 2}}
+	db, users := openTestUserDB(t, opts)
+	for _, usr := range makeTestUsers() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, usr)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if usr.ID%2 == 0 {
+			waitTestCheckpoint(t, dir, uint64(usr.ID))
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// the final checkpoint is taken on Close and older ones pruned
+	want := []string{checkpointName(4), checkpointName(5)}
+	if got := listTestCheckpoints(t, dir); !reflect.DeepEqual(got, want) {
+		t.Errorf("checkpoints = %v, want %v", got, want)
+	}
+
+	// the newest valid checkpoint is loaded
+	if err := os.WriteFile(filepath.Join(dir, checkpointName(6)), []byte("torn"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	db, users = openTestUserDB(t, opts)
+	defer db.Close()
+	if db.Version() != 5 {
+		t.Errorf("DB.Version() = %v, want 5", db.Version())
+	}
+	list, _ := users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, testUserIDs(makeTestUsers())) {
+		t.Errorf("TableLister.All() = %v, want %v", got, testUserIDs(makeTestUsers()))
+	}
+}
+
+func TestDB_Checkpoint_age(t *testing.T) {
+	dir := t.TempDir()
+	db, users := openTestUserDB(t, Options{Checkpoint: &CheckpointOptions{Dir: dir, Age: time.Millisecond}})
+	defer db.Close()
+	tx := db.WriteTx()
+	_ = users.Set(tx, makeTestUsers()[0])
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	waitTestCheckpoint(t, dir, 1)
+}
+
+func TestDB_Checkpoint_WAL(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		Checkpoint: &CheckpointOptions{Dir: dir},
+		WAL:        &WALOptions{Path: filepath.Join(dir, "wal"), Sync: SyncNever},
+	}
+	db, users := openTestUserDB(t, opts)
+	commit := func(usr *testUser) {
+		tx := db.WriteTx()
+		_ = users.Set(tx, usr)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, usr := range makeTestUsers()[:3] {
+		commit(usr)
+	}
+	if err := db.Checkpoint(); err != nil {
+		t.Fatal(err)
+	}
+	// records included in the checkpoint are removed from the log
+	if info, err := os.Stat(opts.WAL.Path); err != nil || info.Size() != 0 {
+		t.Errorf("log size after checkpoint = %v, %v, want 0", info.Size(), err)
+	}
+	for _, usr := range makeTestUsers()[3:] {
+		commit(usr)
+	}
+	// simulate a crash, leaving the last commits in the log only
+	db.wal.close()
+
+	db, users = openTestUserDB(t, opts)
+	defer db.Close()
+	if db.Version() != 5 {
+		t.Errorf("DB.Version() = %v, want 5", db.Version())
+	}
+	list, _ := users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, testUserIDs(makeTestUsers())) {
+		t.Errorf("TableLister.All() = %v, want %v", got, testUserIDs(makeTestUsers()))
+	}
+}
+
+func TestDB_Checkpoint_failedOpen(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		Checkpoint: &CheckpointOptions{Dir: dir},
+		WAL:        &WALOptions{Path: filepath.Join(dir, "wal"), Sync: SyncNever},
+	}
+	db, users := openTestUserDB(t, Options{WAL: opts.WAL})
+	tx := db.WriteTx()
+	_ = users.SetMulti(tx, makeTestUsers())
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	// append a record which is replayed after the first one and fails
+	payload := binary.BigEndian.AppendUint64(nil, 2)
+	payload = binary.AppendUvarint(payload, 1)
+	payload = appendBytes(payload, []byte("unknown"))
+	payload = binary.AppendUvarint(payload, 0)
+	rec := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	rec = binary.BigEndian.AppendUint32(rec, crc32.Checksum(payload, crcTable))
+	f, err := os.OpenFile(opts.WAL.Path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write(append(rec, payload...))
+	_ = f.Close()
+
+	if _, err := InitWithOptions(opts, makeTestUserTable()); !errors.Is(err, ErrInvalidChangeSet) {
+		t.Fatalf("InitWithOptions() error = %v, want %v", err, ErrInvalidChangeSet)
+	}
+	// no checkpoint of the partly replayed log is taken
+	if files := listTestCheckpoints(t, dir); len(files) != 0 {
+		t.Errorf("checkpoints after failed open = %v, want none", files)
+	}
+}
+
+func TestDB_Checkpoint_WALSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{
+		Checkpoint: &CheckpointOptions{Dir: dir},
+		WAL: &WALOptions{
+			Path:     filepath.Join(dir, "wal"),
+			Snapshot: filepath.Join(dir, "snapshot"),
+		},
+	}
+	db, users := openTestUserDB(t, opts)
+	for _, usr := range makeTestUsers() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, usr)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if usr.ID == 1 {
+			f, err := os.Create(opts.WAL.Snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Save(f); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	_ = db.Close()
+
+	// the checkpoint is newer than the snapshot, which is not loaded
+	db, users = openTestUserDB(t, opts)
+	defer db.Close()
+	if db.Version() != 5 {
+		t.Errorf("DB.Version() = %v, want 5", db.Version())
+	}
+	list, _ := users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, testUserIDs(makeTestUsers())) {
+		t.Errorf("TableLister.All() = %v, want %v", got, testUserIDs(makeTestUsers()))
+	}
+}
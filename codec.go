@@ -0,0 +1,41 @@
+package memdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+)
+
+// Codec converts values of a table to bytes and back. It is used
+// whenever rows leave the memory, e.g. when the database is saved.
+type Codec[V any] interface {
+	Marshal(v V) ([]byte, error)
+	Unmarshal(data []byte, v *V) error
+}
+
+// GobCodec encodes values with encoding/gob. It is the default codec
+// of tables.
+type GobCodec[V any] struct{}
+
+func (GobCodec[V]) Marshal(v V) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (GobCodec[V]) Unmarshal(data []byte, v *V) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// JSONCodec encodes values with encoding/json.
+type JSONCodec[V any] struct{}
+
+func (JSONCodec[V]) Marshal(v V) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec[V]) Unmarshal(data []byte, v *V) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,83 @@
+package memdb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodec(t *testing.T) {
+	usr := &testUser{ID: 1, Status: 2, Email: "a@", Name: "Anna"}
+	codecs := map[string]Codec[*testUser]{
+		"gob":  GobCodec[*testUser]{},
+		"json": JSONCodec[*testUser]{},
+	}
+	for name, c := range codecs {
+		t.Run(name, func(t *testing.T) {
+			b, err := c.Marshal(usr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got *testUser
+			if err := c.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, usr) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, usr)
+			}
+		})
+	}
+}
+
+func TestNewTable_options(t *testing.T) {
+	key := func(usr *testUser) Key { return IntKey(usr.ID) }
+	users := NewTable(key, WithName[*testUser]("users"), WithCodec[*testUser](JSONCodec[*testUser]{}))
+	admins := NewTable(key, WithName[*testUser]("admins"))
+	src, err := Init(users, admins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if users.Name() != "users" {
+		t.Errorf("Table.Name() = %q, want %q", users.Name(), "users")
+	}
+	if _, ok := admins.Codec().(GobCodec[*testUser]); !ok {
+		t.Errorf("Table.Codec() = %T, want GobCodec", admins.Codec())
+	}
+	tx := src.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 1, Name: "Anna"})
+	_ = admins.Set(tx, &testUser{ID: 2, Name: "Bob"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `{"ID":1,"Status":0,"Email":"","Name":"Anna"}`) {
+		t.Error("snapshot does not contain the row encoded with the codec of the table")
+	}
+
+	// tables are matched by name, regardless of their order
+	dst, err := Init(admins, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if usr, err := users.Get(dst.ReadTx(), IntKey(1)); err != nil || usr.Name != "Anna" {
+		t.Errorf("Table.Get() = %+v, %v, want Anna", usr, err)
+	}
+	if usr, err := admins.Get(dst.ReadTx(), IntKey(2)); err != nil || usr.Name != "Bob" {
+		t.Errorf("Table.Get() = %+v, %v, want Bob", usr, err)
+	}
+
+	if _, err := Init(users, NewTable(key, WithName[*testUser]("users"))); err == nil {
+		t.Error("Init() with duplicate table names succeeded")
+	}
+	// unnamed tables of the same type are numbered
+	if _, err := Init(NewTable(key), NewTable(key)); err != nil {
+		t.Errorf("Init() error = %v", err)
+	}
+}
@@ -1,7 +1,13 @@
 package memdb
 
 import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
 	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -9,83 +15,455 @@ type callbacks[V any] struct {
 	setfn []func(tx *Txn, v V)
 	updfn []func(tx *Txn, v V, prev V)
 	delfn []func(tx *Txn, v V)
+	// checkfn validate entries before they are set, e.g. against
+	// unique indexes
+	checkfn []func(tx *Txn, k []byte, v V) error
+}
+
+// dbRoot is an immutable version of the database holding trees of
+// all the tables, together with the changes published by the commit
+// which created it. Each root links to the one committed after it,
+// so a transaction can find all the commits made since it started.
+type dbRoot struct {
+	version uint64
+	time    time.Time
+	tm      map[interface{}]map[uint8]unsafe.Pointer
+	changes map[interface{}]*tree[*change]
+	next    *dbRoot
+}
+
+const (
+	DefaultMaxRetries      = 10
+	DefaultRetryBackoff    = time.Millisecond
+	DefaultMaxRetryBackoff = time.Second
+)
+
+type Options struct {
+	// MaxRetries is the number of times Update retries a transaction
+	// which failed to commit due to a conflict. Zero means
+	// DefaultMaxRetries, negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, doubled
+	// with each following one. Zero means DefaultRetryBackoff.
+	RetryBackoff time.Duration
+	// MaxRetryBackoff limits the delay between retries. Zero means
+	// DefaultMaxRetryBackoff.
+	MaxRetryBackoff time.Duration
+	// HistorySize is the number of past versions of the database
+	// retained for ReadTxAt and ReadTxAsOf. Zero means no limit.
+	HistorySize int
+	// HistoryAge is how long past versions are retained after they
+	// have been replaced by a newer one. Zero means no limit.
+	// History is disabled when both HistorySize and HistoryAge are
+	// zero.
+	HistoryAge time.Duration
+	// WAL enables the write-ahead log. Commits are appended to the
+	// log before they are published, and the log is replayed when
+	// the database is initialized.
+	WAL *WALOptions
+	// Checkpoint enables writing checkpoints of the database to a
+	// directory in the background. The newest valid checkpoint is
+	// loaded when the database is initialized, before the write-ahead
+	// log is replayed.
+	Checkpoint *CheckpointOptions
+	// ReadOnly makes all transactions read-only. The database can
+	// only be changed with Load and ApplyChangeSet, e.g. when it is
+	// a replica of another one.
+	ReadOnly bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultMaxRetries
+	} else if o.MaxRetries < 0 {
+		o.MaxRetries = 0
+	}
+	if o.RetryBackoff <= 0 {
+		o.RetryBackoff = DefaultRetryBackoff
+	}
+	if o.MaxRetryBackoff <= 0 {
+		o.MaxRetryBackoff = DefaultMaxRetryBackoff
+	}
+	if o.RetryBackoff > o.MaxRetryBackoff {
+		o.RetryBackoff = o.MaxRetryBackoff
+	}
+	return o
 }
 
 type DB struct {
-	tm       map[interface{}]map[uint8]*unsafe.Pointer
-	txfn     map[interface{}]map[uint8]func(unsafe.Pointer) unsafe.Pointer
-	commitfn map[interface{}]map[uint8]func(unsafe.Pointer) unsafe.Pointer
-	indexm   map[interface{}]int
+	opts        Options
+	mu          sync.Mutex
+	root        unsafe.Pointer
+	txfn        map[interface{}]map[uint8]func(p unsafe.Pointer, write bool) unsafe.Pointer
+	commitfn    map[interface{}]map[uint8]func(unsafe.Pointer) unsafe.Pointer
+	replayfn    map[interface{}]func(tx *Txn, k []byte, c *change) error
+	indexm      map[interface{}]int
+	tables      []TableType
+	names       map[interface{}]string
+	refs        map[interface{}][]*reference
+	subs        map[*Subscription]struct{}
+	keys        map[interface{}]map[string]*keyWatch
+	history     []*dbRoot
+	wal         *wal
+	checkpoints *checkpointer
 }
 
 func Init(tables ...TableType) (*DB, error) {
+	return InitWithOptions(Options{}, tables...)
+}
+
+func InitWithOptions(opts Options, tables ...TableType) (*DB, error) {
 	db := &DB{
-		// root:     unsafe.Pointer(iradix.New[*iradix.Tree[[]byte]]()),
-		tm:       map[interface{}]map[uint8]*unsafe.Pointer{},
-		txfn:     map[interface{}]map[uint8]func(unsafe.Pointer) unsafe.Pointer{},
+		opts: opts.withDefaults(),
+		root: unsafe.Pointer(&dbRoot{
+			time: time.Now(),
+			tm:   map[interface{}]map[uint8]unsafe.Pointer{},
+		}),
+		txfn:     map[interface{}]map[uint8]func(p unsafe.Pointer, write bool) unsafe.Pointer{},
 		commitfn: map[interface{}]map[uint8]func(unsafe.Pointer) unsafe.Pointer{},
+		replayfn: map[interface{}]func(tx *Txn, k []byte, c *change) error{},
 		indexm:   map[interface{}]int{},
+		names:    map[interface{}]string{},
+		refs:     map[interface{}][]*reference{},
+		subs:     map[*Subscription]struct{}{},
+		keys:     map[interface{}]map[string]*keyWatch{},
 	}
 	for _, table := range tables {
 		err := table.registerTable(db)
 		if err != nil {
 			return nil, err
 		}
+		if err := db.nameTable(table); err != nil {
+			return nil, err
+		}
+		db.tables = append(db.tables, table)
+	}
+	if err := db.checkRefs(); err != nil {
+		return nil, err
+	}
+	if opts.Checkpoint != nil {
+		if err := db.openCheckpoints(*opts.Checkpoint); err != nil {
+			return nil, err
+		}
+	}
+	if opts.WAL != nil {
+		if err := db.openWAL(*opts.WAL); err != nil {
+			// a checkpoint of the partly replayed log would replace
+			// the commits which are not replayed
+			if db.checkpoints != nil {
+				db.checkpoints.stop()
+			}
+			return nil, err
+		}
+	}
+	if db.checkpoints != nil {
+		db.checkpoints.start()
 	}
 	return db, nil
 }
 
-func (db *DB) Tx(write bool) *Txn {
-	tm := make(map[interface{}]map[uint8]unsafe.Pointer, len(db.tm))
+// nameTable assigns a unique name to the table. Tables named after
+// the type of their values are numbered if there are more of them.
+func (db *DB) nameTable(t TableType) error {
+	name := t.tableName()
+	named := name != ""
+	if !named {
+		name = reflect.TypeOf(t.tableRef()).Elem().String()
+	}
+	taken := func(name string) bool {
+		for _, n := range db.names {
+			if n == name {
+				return true
+			}
+		}
+		return false
+	}
+	if taken(name) {
+		if named {
+			return fmt.Errorf("memdb: table name %q already registered", name)
+		}
+		base := name
+		for i := 2; taken(name); i++ {
+			name = base + "#" + strconv.Itoa(i)
+		}
+	}
+	db.names[t.tableRef()] = name
+	return nil
+}
+
+// load returns the latest committed root.
+func (db *DB) load() *dbRoot {
+	return (*dbRoot)(atomic.LoadPointer(&db.root))
+}
+
+func (db *DB) Tx(write bool, opts ...TxOption) *Txn {
+	return db.txAt(db.load(), write && !db.opts.ReadOnly, opts)
+}
+
+// writeTx starts a write transaction, also on read-only databases.
+func (db *DB) writeTx() *Txn {
+	return db.txAt(db.load(), true, nil)
+}
+
+func (db *DB) txAt(root *dbRoot, write bool, opts []TxOption) *Txn {
+	tm := make(map[interface{}]map[uint8]unsafe.Pointer, len(root.tm))
 	for ref, n := range db.indexm {
 		v := n
 		tm[ref] = make(map[uint8]unsafe.Pointer, v)
 		for j := 0; j <= v; j++ {
-			p := db.tm[ref][uint8(j)]
-			tm[ref][uint8(j)] = db.txfn[ref][uint8(j)](atomic.LoadPointer(p))
+			tm[ref][uint8(j)] = db.txfn[ref][uint8(j)](root.tm[ref][uint8(j)], write)
 		}
 	}
-	return &Txn{
-		// root:  root.Txn(),
-		db:    db,
-		tm:    tm,
-		write: write,
+	tx := &Txn{
+		root:    root,
+		base:    root.version,
+		version: root.version,
+		db:      db,
+		tm:      tm,
+		changes: map[interface{}]*treeTxn[*change]{},
+		write:   write,
 	}
+	for _, opt := range opts {
+		opt(tx)
+	}
+	return tx
 }
 
+// Txn is a transaction reading a snapshot of the database and, if it
+// is a write transaction, modifying it. An open transaction keeps the
+// version of the database it has started from reachable, together with
+// all the versions committed after it, so transactions should not be
+// kept open longer than needed. Committed and aborted transactions do
+// not keep any versions.
 type Txn struct {
-	write bool
-	db    *DB
-	tm    map[interface{}]map[uint8]unsafe.Pointer
+	write   bool
+	db      *DB
+	ctx     context.Context
+	root    *dbRoot
+	base    uint64 // version of the root, kept once the root is released
+	version uint64
+	// applied is the version of the change set or the snapshot the
+	// transaction applies, published according to applyMode
+	applied   uint64
+	applyMode applyMode
+	tm        map[interface{}]map[uint8]unsafe.Pointer
+	changes   map[interface{}]*treeTxn[*change]
+	reads     map[interface{}]*readSet
+
+	onCommit []func(version uint64)
+	onAbort  []func()
 }
 
-func (tx *Txn) Commit() {
-	if !tx.write {
-		return
+// check returns an error if the transaction can not be used for
+// reading or, if write is set, for modifying tables.
+func (tx *Txn) check(write bool) error {
+	if tx.tm == nil {
+		return ErrTxnDone
+	}
+	if tx.ctx != nil {
+		if err := tx.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if write && !tx.write {
+		return ErrTxnReadOnly
+	}
+	return nil
+}
+
+// Commit publishes changes made by the transaction. If other
+// transactions have committed since this one started, its changes
+// are applied on top of theirs, unless any of them has modified an
+// entry which was modified by this transaction as well, in which
+// case ErrConflict is returned and the transaction is discarded. It
+// is discarded with UniqueViolationError as well, if its entries would
+// share keys of unique indexes with the committed ones.
+func (tx *Txn) Commit() error {
+	if err := tx.check(false); err != nil {
+		if err != ErrTxnDone {
+			tx.Abort()
+		}
+		return err
+	}
+	version, err := tx.commit()
+	tx.release()
+	if err != nil {
+		tx.runAbortHooks()
+		return err
+	}
+	tx.version = version
+	tx.db.waitSubscribers(version)
+	for _, fn := range tx.onCommit {
+		fn(version)
+	}
+	return nil
+}
+
+// commit publishes changes of the transaction and returns the version
+// of the database which includes them.
+func (tx *Txn) commit() (uint64, error) {
+	if !tx.write || (len(tx.changes) == 0 && tx.applied == 0 && tx.applyMode != applyExact) {
+		return tx.root.version, nil
+	}
+	db := tx.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	head := db.load()
+	version, err := tx.publishVersion(head)
+	if err != nil {
+		return 0, err
 	}
-	if tx.tm == nil /** || tx.root == nil */ {
-		return // already committed
+	if version == head.version && len(tx.changes) == 0 {
+		// a snapshot of the latest version without any changes
+		return version, nil
 	}
-	for ref, v := range tx.tm {
+	src := tx
+	if head != tx.root {
+		if tx.conflicts() || tx.readConflicts() {
+			return 0, ErrConflict
+		}
+		rtx, err := tx.rebase()
+		if err != nil {
+			return 0, err
+		}
+		src = rtx
+	}
+	root := &dbRoot{
+		version: version,
+		time:    time.Now(),
+		tm:      make(map[interface{}]map[uint8]unsafe.Pointer, len(src.tm)),
+		changes: make(map[interface{}]*tree[*change], len(src.changes)),
+	}
+	for ref, v := range src.tm {
+		root.tm[ref] = make(map[uint8]unsafe.Pointer, len(v))
 		for j, p := range v {
-			atomic.StorePointer(tx.db.tm[ref][j], tx.db.commitfn[ref][j](p))
+			root.tm[ref][j] = db.commitfn[ref][j](p)
 		}
 	}
-	tx.tm = nil
+	if db.wal != nil {
+		rec, err := db.encodeChanges(version, src.changes)
+		if err != nil {
+			return 0, err
+		}
+		if err := db.wal.append(rec); err != nil {
+			return 0, err
+		}
+	}
+	for ref, changes := range src.changes {
+		root.changes[ref] = changes.commit()
+	}
+	head.next = root
+	// trees of all the tables are published at once, so readers
+	// always see either all the changes of the commit or none
+	atomic.StorePointer(&db.root, unsafe.Pointer(root))
+	if version > head.version {
+		db.retain(head, root.time)
+	} else {
+		// versions of the history would be taken again by the
+		// following commits
+		db.history = nil
+	}
+	db.notifyKeys(root)
+	db.publish(root)
+	if db.checkpoints != nil {
+		db.checkpoints.committed()
+	}
+	return root.version, nil
 }
 
-func (tx *Txn) Abort() {
-	if !tx.write {
-		return
+// durable reports whether commits are written to the log or to
+// checkpoints.
+func (db *DB) durable() bool {
+	return db.wal != nil || db.checkpoints != nil
+}
+
+// Close stops background checkpoints, taking the final one, and
+// flushes and closes the write-ahead log. Commits made after the
+// database is closed fail with ErrClosed if the log is enabled.
+func (db *DB) Close() error {
+	var err error
+	if db.checkpoints != nil {
+		err = db.checkpoints.close()
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.wal != nil {
+		if werr := db.wal.close(); err == nil {
+			err = werr
+		}
+	}
+	return err
+}
+
+// conflicts reports whether any commit made since the transaction
+// has started modified an entry modified by the transaction.
+func (tx *Txn) conflicts() bool {
+	for c := tx.root.next; c != nil; c = c.next {
+		for ref, theirs := range c.changes {
+			ours, ok := tx.changes[ref]
+			if !ok {
+				continue
+			}
+			if ours.commit().intersect(theirs).root != nil {
+				return true
+			}
+		}
 	}
+	return false
+}
+
+// rebase replays changes of the transaction on top of the latest
+// commit. It must be called with the commit lock held.
+func (tx *Txn) rebase() (*Txn, error) {
+	rtx := tx.db.writeTx()
+	for ref, changes := range tx.changes {
+		replay := tx.db.replayfn[ref]
+		c := changes.cursor()
+		ok := c.first()
+		for ok {
+			if err := replay(rtx, c.key(), c.val()); err != nil {
+				return nil, err
+			}
+			ok = c.next()
+		}
+	}
+	if err := tx.db.validate(rtx); err != nil {
+		return nil, err
+	}
+	return rtx, nil
+}
+
+// validate checks entries set and deleted by the transaction with
+// replayed changes.
+func (db *DB) validate(tx *Txn) error {
+	for _, t := range db.tables {
+		if err := t.validate(tx); err != nil {
+			return err
+		}
+	}
+	return db.validateRefs(tx)
+}
+
+// Abort discards the transaction. It does nothing if the transaction
+// has already been committed or aborted, so it is safe to defer it.
+func (tx *Txn) Abort() {
 	if tx.tm == nil {
 		return
 	}
+	tx.release()
+	tx.runAbortHooks()
+}
+
+// release drops the trees and the root of the finished transaction, so
+// it does not keep versions of the database reachable.
+func (tx *Txn) release() {
 	tx.tm = nil
+	tx.root = nil
+	tx.reads = nil
 }
 
-func (db *DB) WriteTx() *Txn {
-	return db.Tx(true)
+func (db *DB) WriteTx(opts ...TxOption) *Txn {
+	return db.Tx(true, opts...)
 }
 
 func (db *DB) ReadTx() *Txn {
@@ -0,0 +1,54 @@
+package memdb
+
+import "context"
+
+// interruptInterval is the number of tree traversal steps after which
+// queries check whether their context is done.
+const interruptInterval = 256
+
+// WriteTxContext starts a write transaction bound to ctx. Once ctx is
+// done, operations of the transaction return its error.
+func (db *DB) WriteTxContext(ctx context.Context, opts ...TxOption) *Txn {
+	tx := db.Tx(true, opts...)
+	tx.ctx = ctx
+	return tx
+}
+
+// ReadTxContext starts a read transaction bound to ctx. Once ctx is
+// done, operations of the transaction return its error.
+func (db *DB) ReadTxContext(ctx context.Context) *Txn {
+	tx := db.Tx(false)
+	tx.ctx = ctx
+	return tx
+}
+
+// Context returns the context the transaction is bound to.
+func (tx *Txn) Context() context.Context {
+	if tx.ctx == nil {
+		return context.Background()
+	}
+	return tx.ctx
+}
+
+// interrupter checks periodically whether a long running traversal
+// should be stopped because its context is done.
+type interrupter struct {
+	ctx   context.Context
+	steps int
+	err   error
+}
+
+func (i *interrupter) interrupted() bool {
+	if i.err != nil {
+		return true
+	}
+	if i.ctx == nil {
+		return false
+	}
+	i.steps++
+	if i.steps%interruptInterval != 0 {
+		return false
+	}
+	i.err = i.ctx.Err()
+	return i.err != nil
+}
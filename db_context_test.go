@@ -0,0 +1,139 @@
+package memdb
+
+import (
+	"context"
+	"testing"
+)
+
+func makeTestUserDBN(t *testing.T, n int) (*DB, testUserTable) {
+	users := make([]*testUser, n)
+	for i := range users {
+		users[i] = &testUser{ID: i + 1, Status: i % 3}
+	}
+	return makeTestUserDB(t, users...)
+}
+
+func TestDB_ReadTxContext(t *testing.T) {
+	db, users := makeTestUserDBN(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := db.ReadTxContext(ctx)
+	if tx.Context() != ctx {
+		t.Fatal("Txn.Context() does not return the context of the transaction")
+	}
+	if _, err := users.Get(tx, IntKey(1)); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := users.Get(tx, IntKey(1)); err != context.Canceled {
+		t.Errorf("Table.Get() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := users.Select(tx).All(); err != context.Canceled {
+		t.Errorf("TableLister.All() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDB_WriteTxContext(t *testing.T) {
+	db, users := makeTestUserDBN(t, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	tx := db.WriteTxContext(ctx)
+	aborted := false
+	tx.OnAbort(func() { aborted = true })
+	if err := users.Set(tx, &testUser{ID: 11}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := users.Set(tx, &testUser{ID: 12}); err != context.Canceled {
+		t.Errorf("Table.Set() error = %v, want %v", err, context.Canceled)
+	}
+	if err := tx.Commit(); err != context.Canceled {
+		t.Errorf("Txn.Commit() error = %v, want %v", err, context.Canceled)
+	}
+	if !aborted {
+		t.Error("transaction was not aborted")
+	}
+	if _, err := users.Get(db.ReadTx(), IntKey(11)); err != ErrNotFound {
+		t.Errorf("Table.Get() error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestTableLister_WithContext(t *testing.T) {
+	db, users := makeTestUserDBN(t, 2000)
+	// the context is cancelled in the middle of the traversal
+	cancelling := func() (context.Context, Cond[*testUser]) {
+		ctx, cancel := context.WithCancel(context.Background())
+		n := 0
+		return ctx, CondFunc[*testUser](func(usr *testUser) bool {
+			if n++; n == 500 {
+				cancel()
+			}
+			return true
+		})
+	}
+	tests := []struct {
+		name string
+		run  func(l *TableLister[*testUser]) error
+	}{
+		{"all", func(l *TableLister[*testUser]) error {
+			_, err := l.All()
+			return err
+		}},
+		{"page", func(l *TableLister[*testUser]) error {
+			_, err := l.Page(10, 1900)
+			return err
+		}},
+		{"count", func(l *TableLister[*testUser]) error {
+			_, err := l.Count()
+			return err
+		}},
+		{"ordered", func(l *TableLister[*testUser]) error {
+			_, err := l.OrderBy(users.status.Desc()).All()
+			return err
+		}},
+		{"indexed", func(l *TableLister[*testUser]) error {
+			_, err := l.Where(users.status.IsGreaterThanOrEqual(0)).All()
+			return err
+		}},
+		{"cursor", func(l *TableLister[*testUser]) error {
+			c, err := l.Cursor()
+			if err != nil {
+				return err
+			}
+			n := 0
+			for _, ok := c.First(); ok; _, ok = c.Next() {
+				n++
+			}
+			if n == 2000 {
+				t.Error("cursor iterated over all the entries")
+			}
+			return c.Err()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cond := cancelling()
+			l := users.Select(db.ReadTx()).Where(cond).WithContext(ctx)
+			if err := tt.run(l); err != context.Canceled {
+				t.Errorf("error = %v, want %v", err, context.Canceled)
+			}
+		})
+	}
+	t.Run("seek", func(t *testing.T) {
+		// the group of the entry is read in whole to seek within it
+		ctx, cancel := context.WithCancel(context.Background())
+		c, err := users.Select(db.ReadTx()).OrderBy(users.status.Asc(), users.name.Asc()).WithContext(ctx).Cursor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		if _, ok := c.Seek(IntKey(1000)); ok || c.Err() != context.Canceled {
+			t.Errorf("TableCursor.Seek() = %v, error = %v, want %v", ok, c.Err(), context.Canceled)
+		}
+	})
+	t.Run("done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := users.Select(db.ReadTx()).WithContext(ctx).Count(); err != context.Canceled {
+			t.Errorf("TableLister.Count() error = %v, want %v", err, context.Canceled)
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package memdb
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Update runs fn within a write transaction. The transaction is
+// committed if fn returns nil and aborted if it returns an error or
+// panics. If the commit fails due to a conflict, fn is run again in
+// a new transaction, up to the number of retries set in Options.
+func (db *DB) Update(ctx context.Context, fn func(tx *Txn) error, opts ...TxOption) error {
+	backoff := db.opts.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		err := db.update(ctx, fn, opts)
+		if err != ErrConflict || attempt >= db.opts.MaxRetries {
+			return err
+		}
+		// randomize the delay, so conflicting transactions
+		// are unlikely to be retried at the same time
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if backoff < db.opts.MaxRetryBackoff/2 {
+			backoff *= 2
+		} else {
+			backoff = db.opts.MaxRetryBackoff
+		}
+	}
+}
+
+func (db *DB) update(ctx context.Context, fn func(tx *Txn) error, opts []TxOption) error {
+	tx := db.WriteTxContext(ctx, opts...)
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Abort()
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		tx.Abort()
+		return err
+	}
+	return tx.Commit()
+}
+
+// View runs fn within a read transaction.
+func (db *DB) View(fn func(tx *Txn) error) error {
+	tx := db.ReadTx()
+	defer tx.Abort()
+	return fn(tx)
+}
@@ -0,0 +1,136 @@
+package memdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDB_Update(t *testing.T) {
+	db, users := openTestUserDB(t, Options{MaxRetries: 1000})
+	const workers, increments = 8, 50
+	done := make(chan error)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for n := 0; n < increments; n++ {
+				err := db.Update(context.Background(), func(tx *Txn) error {
+					usr, err := users.Get(tx, IntKey(1))
+					if err == ErrNotFound {
+						usr = &testUser{ID: 1}
+					} else if err != nil {
+						return err
+					}
+					return users.Set(tx, &testUser{ID: 1, Status: usr.Status + 1})
+				})
+				if err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		if err := <-done; err != nil {
+			t.Fatalf("DB.Update() error = %v", err)
+		}
+	}
+	err := db.View(func(tx *Txn) error {
+		usr, err := users.Get(tx, IntKey(1))
+		if err != nil {
+			return err
+		}
+		if usr.Status != workers*increments {
+			t.Errorf("Table.Get() status = %v, want %v", usr.Status, workers*increments)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DB.View() error = %v", err)
+	}
+}
+
+func TestDB_Update_abort(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	errTest := errors.New("test")
+	err := db.Update(context.Background(), func(tx *Txn) error {
+		if err := users.Set(tx, &testUser{ID: 1}); err != nil {
+			return err
+		}
+		return errTest
+	})
+	if err != errTest {
+		t.Fatalf("DB.Update() error = %v, want %v", err, errTest)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != errTest {
+				t.Fatalf("DB.Update() panic = %v, want %v", r, errTest)
+			}
+		}()
+		_ = db.Update(context.Background(), func(tx *Txn) error {
+			if err := users.Set(tx, &testUser{ID: 2}); err != nil {
+				return err
+			}
+			panic(errTest)
+		})
+	}()
+	if n, _ := users.Select(db.ReadTx()).Count(); n != 0 {
+		t.Errorf("TableLister.Count() = %v, want 0", n)
+	}
+}
+
+func TestDB_Update_retries(t *testing.T) {
+	db, users := openTestUserDB(t, Options{MaxRetries: 2})
+	attempts := 0
+	err := db.Update(context.Background(), func(tx *Txn) error {
+		attempts++
+		// commit a conflicting change before this transaction does
+		other := db.WriteTx()
+		if err := users.Set(other, &testUser{ID: 1, Status: attempts}); err != nil {
+			return err
+		}
+		if err := other.Commit(); err != nil {
+			return err
+		}
+		return users.Set(tx, &testUser{ID: 1})
+	})
+	if err != ErrConflict {
+		t.Fatalf("DB.Update() error = %v, want %v", err, ErrConflict)
+	}
+	if attempts != 3 {
+		t.Errorf("DB.Update() attempts = %v, want 3", attempts)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = db.Update(ctx, func(tx *Txn) error {
+		return nil
+	})
+	if err != context.Canceled {
+		t.Errorf("DB.Update() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDB_Update_maxRetryBackoff(t *testing.T) {
+	// doubling the backoff of this many retries would overflow
+	db, users := openTestUserDB(t, Options{MaxRetries: 100, MaxRetryBackoff: time.Millisecond})
+	attempts := 0
+	err := db.Update(context.Background(), func(tx *Txn) error {
+		attempts++
+		other := db.WriteTx()
+		if err := users.Set(other, &testUser{ID: 1, Status: attempts}); err != nil {
+			return err
+		}
+		if err := other.Commit(); err != nil {
+			return err
+		}
+		return users.Set(tx, &testUser{ID: 1})
+	})
+	if err != ErrConflict {
+		t.Fatalf("DB.Update() error = %v, want %v", err, ErrConflict)
+	}
+	if attempts != 101 {
+		t.Errorf("DB.Update() attempts = %v, want 101", attempts)
+	}
+}
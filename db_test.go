@@ -0,0 +1,172 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxn_Commit_conflict(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx1 := db.WriteTx()
+	tx2 := db.WriteTx()
+	if err := users.Set(tx1, &testUser{ID: 1, Status: 2, Name: "Dave"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Set(tx2, &testUser{ID: 1, Status: 0, Name: "David"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Txn.Commit() error = %v", err)
+	}
+	if err := tx2.Commit(); err != ErrConflict {
+		t.Fatalf("Txn.Commit() error = %v, want %v", err, ErrConflict)
+	}
+	usr, err := users.Get(db.ReadTx(), IntKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.Name != "Dave" || usr.Status != 2 {
+		t.Errorf("Table.Get() = %+v, want changes of the first commit", usr)
+	}
+}
+
+func TestTxn_Commit_deleteConflict(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx1 := db.WriteTx()
+	tx2 := db.WriteTx()
+	if err := users.Del(tx1, IntKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Set(tx2, &testUser{ID: 2, Name: "Anna"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Txn.Commit() error = %v", err)
+	}
+	if err := tx2.Commit(); err != ErrConflict {
+		t.Fatalf("Txn.Commit() error = %v, want %v", err, ErrConflict)
+	}
+}
+
+func TestTxn_Commit_rebase(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx1 := db.WriteTx()
+	tx2 := db.WriteTx()
+	if err := users.Set(tx1, &testUser{ID: 1, Status: 2, Name: "Dave"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Set(tx2, &testUser{ID: 6, Status: 2, Name: "Fred"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Del(tx2, IntKey(4)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); err != nil {
+		t.Fatalf("Txn.Commit() error = %v", err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatalf("Txn.Commit() error = %v", err)
+	}
+	tx := db.ReadTx()
+	all, err := users.Select(tx).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testUserIDs(all), []int{1, 2, 3, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TableLister.All() = %v, want %v", got, want)
+	}
+	list, err := users.Select(tx).Where(users.status.Is(2)).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testUserIDs(list), []int{1, 5, 6}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TableLister.All() = %v, want %v", got, want)
+	}
+}
+
+func TestTxn_Commit_concurrent(t *testing.T) {
+	db, users := makeTestUserDB(t, &testUser{ID: 1})
+	const workers, increments = 8, 50
+	done := make(chan struct{})
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for n := 0; n < increments; {
+				tx := db.WriteTx()
+				usr, err := users.Get(tx, IntKey(1))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := users.Set(tx, &testUser{ID: 1, Status: usr.Status + 1}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := tx.Commit(); err == nil {
+					n++
+				} else if err != ErrConflict {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < workers; i++ {
+		<-done
+	}
+	usr, err := users.Get(db.ReadTx(), IntKey(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usr.Status != workers*increments {
+		t.Errorf("Table.Get() status = %v, want %v", usr.Status, workers*increments)
+	}
+}
+
+func TestDB_ReadTx_atomic(t *testing.T) {
+	users, backup := makeTestUserTable(), makeTestUserTable()
+	db, err := Init(users, backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const commits = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= commits; i++ {
+			tx := db.WriteTx()
+			_ = users.Set(tx, &testUser{ID: 1, Status: i})
+			_ = backup.Set(tx, &testUser{ID: 1, Status: i})
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		tx := db.ReadTx()
+		usr, err1 := users.Get(tx, IntKey(1))
+		bak, err2 := backup.Get(tx, IntKey(1))
+		if err1 != nil || err2 != nil {
+			if err1 != err2 {
+				t.Fatalf("Table.Get() errors = %v, %v, want equal", err1, err2)
+			}
+			continue
+		}
+		if usr.Status != bak.Status {
+			t.Fatalf("Table.Get() status = %v, %v, want equal", usr.Status, bak.Status)
+		}
+		n, err := users.Select(tx).Where(users.status.Is(usr.Status)).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 1 {
+			t.Fatalf("TableLister.Count() = %v, want 1", n)
+		}
+	}
+}
@@ -4,4 +4,20 @@ import "errors"
 
 var (
 	ErrNotFound = errors.New("memdb: not found")
+	ErrConflict = errors.New("memdb: transaction conflicts with a concurrent commit")
+
+	ErrMergeConflict       = errors.New("memdb: merge has unresolved conflicts")
+	ErrUniqueViolation     = errors.New("memdb: unique index violation")
+	ErrForeignKeyViolation = errors.New("memdb: foreign key violation")
+
+	ErrVersionNotFound  = errors.New("memdb: version not found")
+	ErrInvalidSnapshot  = errors.New("memdb: invalid snapshot")
+	ErrInvalidChangeSet = errors.New("memdb: invalid change set")
+	ErrVersionGap       = errors.New("memdb: change set does not follow the current version")
+	ErrVersionRewind    = errors.New("memdb: durable database can not go back to an older version")
+	ErrClosed           = errors.New("memdb: database is closed")
+	ErrLagging          = errors.New("memdb: subscriber did not keep up with commits")
+
+	ErrTxnReadOnly = errors.New("memdb: transaction is read-only")
+	ErrTxnDone     = errors.New("memdb: transaction has already been committed or aborted")
 )
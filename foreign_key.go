@@ -0,0 +1,217 @@
+package memdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// RefAction is the action taken on entries referencing an entry of
+// another table when the referenced entry is deleted.
+type RefAction int
+
+const (
+	// RefRestrict makes deleting referenced entries fail.
+	RefRestrict RefAction = iota
+	// RefCascade deletes the referencing entries as well.
+	RefCascade
+	// RefSetNull clears the reference of the referencing entries with
+	// the Null function of the foreign key.
+	RefSetNull
+)
+
+// ForeignKey declares that keys of an index of a table are primary
+// keys of entries of the parent table.
+type ForeignKey[V any] struct {
+	Index    Index[V]
+	Parent   TableType
+	OnDelete RefAction
+	// Null returns a copy of the entry with the reference cleared. It
+	// is required by RefSetNull. If it is set, entries with the same
+	// key in the index as the cleared entry do not reference anything.
+	Null func(v V) V
+}
+
+// References adds the foreign key to the table. Set fails with
+// ForeignKeyError for entries referencing missing parent entries, and
+// Del on the parent table applies the OnDelete action to entries
+// referencing the deleted one, within the same transaction. The parent
+// table must be initialized in the same database.
+func (t Table[V]) References(fk ForeignKey[V]) Table[V] {
+	parent := fk.Parent.tableRef()
+	isNull := func(v V, k []byte) bool {
+		return fk.Null != nil && bytes.Equal(k, fk.Index.KeyOf(fk.Null(v)).Bytes())
+	}
+	t.cb.checkfn = append(t.cb.checkfn, func(tx *Txn, k []byte, v V) error {
+		pk := fk.Index.KeyOf(v).Bytes()
+		if isNull(v, pk) || fk.Parent.has(tx, pk) {
+			return nil
+		}
+		return &ForeignKeyError{
+			Table:  tx.db.names[t.ref],
+			Index:  fk.Index,
+			Entry:  k,
+			Parent: tx.db.names[parent],
+			Key:    pk,
+		}
+	})
+	t.refs = append(t.refs, func(t Table[V]) (*reference, error) {
+		pos, ok := t.idxm.m[fk.Index]
+		if !ok {
+			return nil, errors.New("memdb: foreign key index does not belong to the table")
+		}
+		if fk.OnDelete == RefSetNull && fk.Null == nil {
+			return nil, errors.New("memdb: foreign key with RefSetNull action requires Null function")
+		}
+		r := &reference{parent: parent}
+		// referencing returns primary keys of entries referencing the
+		// parent entry under key k
+		referencing := func(tx *Txn, k []byte) [][]byte {
+			idx := (*treeTxn[*tree[struct{}]])(tx.tm[t.ref][uint8(pos+1)])
+			ids, ok := idx.get(k)
+			if !ok {
+				return nil
+			}
+			out := [][]byte{}
+			c := ids.txn(false).cursor()
+			ok = c.first()
+			for ok {
+				out = append(out, c.key())
+				ok = c.next()
+			}
+			return out
+		}
+		r.check = func(tx *Txn, k []byte) error {
+			ids := referencing(tx, k)
+			if len(ids) == 0 {
+				return nil
+			}
+			return &ForeignKeyError{
+				Table:      tx.db.names[t.ref],
+				Index:      fk.Index,
+				Entry:      ids[0],
+				Parent:     tx.db.names[parent],
+				Key:        k,
+				referenced: true,
+			}
+		}
+		r.deleted = func(tx *Txn, k []byte) error {
+			if fk.OnDelete == RefRestrict {
+				return r.check(tx, k)
+			}
+			data, err := t.data(tx, true)
+			if err != nil {
+				return err
+			}
+			for _, id := range referencing(tx, k) {
+				if fk.OnDelete == RefCascade {
+					// the outermost DelMulti rolls back all the
+					// cascaded deletes if any of them fails
+					if err := t.delete(tx, data, id); err != nil {
+						return err
+					}
+					continue
+				}
+				v, ok := data.get(id)
+				if !ok {
+					continue
+				}
+				if err := t.Set(tx, fk.Null(v)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return r, nil
+	})
+	return t
+}
+
+// reference is a foreign key of a child table registered in the
+// database.
+type reference struct {
+	parent interface{}
+	// check returns an error if any child entry references the parent
+	// entry under key k
+	check func(tx *Txn, k []byte) error
+	// deleted applies the action of the foreign key to child entries
+	// referencing the deleted parent entry under key k
+	deleted func(tx *Txn, k []byte) error
+}
+
+// ForeignKeyError is returned when an entry would reference a missing
+// entry of the parent table, or when a referenced entry is deleted with
+// RefRestrict action. It matches ErrForeignKeyViolation with errors.Is.
+type ForeignKeyError struct {
+	// Table is the name of the referencing table.
+	Table string
+	// Index is the index of the foreign key.
+	Index interface{}
+	// Entry is the primary key of the referencing entry.
+	Entry []byte
+	// Parent is the name of the referenced table.
+	Parent string
+	// Key is the primary key of the referenced entry.
+	Key        []byte
+	referenced bool
+}
+
+func (e *ForeignKeyError) Error() string {
+	if e.referenced {
+		return fmt.Sprintf("memdb: entry %x of table %s is referenced by entry %x of table %s",
+			e.Key, e.Parent, e.Entry, e.Table)
+	}
+	return fmt.Sprintf("memdb: entry %x of table %s references missing entry %x of table %s",
+		e.Entry, e.Table, e.Key, e.Parent)
+}
+
+func (e *ForeignKeyError) Is(err error) bool {
+	return err == ErrForeignKeyViolation
+}
+
+// registerRefs registers foreign keys of the table in the database.
+func (t Table[V]) registerRefs(db *DB) error {
+	for _, fn := range t.refs {
+		r, err := fn(t)
+		if err != nil {
+			return err
+		}
+		db.refs[r.parent] = append(db.refs[r.parent], r)
+	}
+	return nil
+}
+
+// checkRefs returns an error if any of the parent tables of foreign
+// keys is not registered in the database.
+func (db *DB) checkRefs() error {
+	for parent := range db.refs {
+		if _, ok := db.indexm[parent]; !ok {
+			return fmt.Errorf("memdb: table %T referenced by a foreign key is not registered", parent)
+		}
+	}
+	return nil
+}
+
+// validateRefs returns an error if any entry deleted by the transaction
+// without applying actions of foreign keys is still referenced.
+func (db *DB) validateRefs(tx *Txn) error {
+	for parent, refs := range db.refs {
+		changes, ok := tx.changes[parent]
+		if !ok {
+			continue
+		}
+		c := changes.cursor()
+		ok = c.first()
+		for ok {
+			if !c.val().exists {
+				for _, r := range refs {
+					if err := r.check(tx, c.key()); err != nil {
+						return err
+					}
+				}
+			}
+			ok = c.next()
+		}
+	}
+	return nil
+}
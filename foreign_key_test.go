@@ -0,0 +1,221 @@
+package memdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testOrder struct {
+	ID     int
+	UserID int
+}
+
+type testOrderTable struct {
+	Table[*testOrder]
+	user *IntIndex[*testOrder]
+}
+
+func makeTestOrderDB(t *testing.T, onDelete RefAction) (*DB, testUserTable, testOrderTable) {
+	t.Helper()
+	users := makeTestUserTable()
+	table := NewTable(func(o *testOrder) Key {
+		return IntKey(o.ID)
+	})
+	table, user := table.IndexInt(func(o *testOrder) int {
+		return o.UserID
+	})
+	table = table.References(ForeignKey[*testOrder]{
+		Index:    user,
+		Parent:   users,
+		OnDelete: onDelete,
+		Null: func(o *testOrder) *testOrder {
+			return &testOrder{ID: o.ID}
+		},
+	})
+	orders := testOrderTable{Table: table, user: user}
+	db, err := Init(orders, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.WriteTx()
+	_ = users.SetMulti(tx, makeTestUsers())
+	err = orders.SetMulti(tx, []*testOrder{
+		{ID: 1, UserID: 1},
+		{ID: 2, UserID: 1},
+		{ID: 3, UserID: 2},
+		{ID: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return db, users, orders
+}
+
+func TestTable_References_set(t *testing.T) {
+	db, _, orders := makeTestOrderDB(t, RefRestrict)
+	tx := db.WriteTx()
+	defer tx.Abort()
+	err := orders.Set(tx, &testOrder{ID: 5, UserID: 42})
+	if !errors.Is(err, ErrForeignKeyViolation) {
+		t.Fatalf("Table.Set() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+	var fkerr *ForeignKeyError
+	if !errors.As(err, &fkerr) || fkerr.Index != orders.user || !reflect.DeepEqual(fkerr.Key, IntKey(42).Bytes()) {
+		t.Errorf("Table.Set() error = %+v", err)
+	}
+	if err := orders.Set(tx, &testOrder{ID: 5, UserID: 3}); err != nil {
+		t.Errorf("Table.Set() error = %v", err)
+	}
+}
+
+func TestTable_References_delete(t *testing.T) {
+	tests := []struct {
+		name     string
+		onDelete RefAction
+		wantErr  error
+		want     []*testOrder
+	}{
+		{
+			name:     "restrict",
+			onDelete: RefRestrict,
+			wantErr:  ErrForeignKeyViolation,
+			want:     []*testOrder{{ID: 1, UserID: 1}, {ID: 2, UserID: 1}, {ID: 3, UserID: 2}, {ID: 4}},
+		},
+		{
+			name:     "cascade",
+			onDelete: RefCascade,
+			want:     []*testOrder{{ID: 3, UserID: 2}, {ID: 4}},
+		},
+		{
+			name:     "set null",
+			onDelete: RefSetNull,
+			want:     []*testOrder{{ID: 1}, {ID: 2}, {ID: 3, UserID: 2}, {ID: 4}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, users, orders := makeTestOrderDB(t, tt.onDelete)
+			tx := db.WriteTx()
+			defer tx.Abort()
+			err := users.DelMulti(tx, []Key{IntKey(5), IntKey(1)})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Table.DelMulti() error = %v, want %v", err, tt.wantErr)
+			}
+			_, err = users.Get(tx, IntKey(5))
+			if (err == nil) != (tt.wantErr != nil) {
+				t.Errorf("Table.Get() of deleted entry error = %v", err)
+			}
+			list, _ := orders.Select(tx).All()
+			if !reflect.DeepEqual(list, tt.want) {
+				t.Errorf("TableLister.All() = %+v, want %+v", list, tt.want)
+			}
+		})
+	}
+}
+
+func TestTable_References_cascade(t *testing.T) {
+	type testItem struct {
+		ID      int
+		OrderID int
+	}
+	users := makeTestUserTable()
+	orders := NewTable(func(o *testOrder) Key {
+		return IntKey(o.ID)
+	})
+	orders, user := orders.IndexInt(func(o *testOrder) int {
+		return o.UserID
+	})
+	orders = orders.References(ForeignKey[*testOrder]{Index: user, Parent: users, OnDelete: RefCascade})
+	items := NewTable(func(i *testItem) Key {
+		return IntKey(i.ID)
+	})
+	items, order := items.IndexInt(func(i *testItem) int {
+		return i.OrderID
+	})
+	items = items.References(ForeignKey[*testItem]{Index: order, Parent: orders, OnDelete: RefRestrict})
+	db, err := Init(users, orders, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.WriteTx()
+	defer tx.Abort()
+	_ = users.SetMulti(tx, makeTestUsers())
+	_ = orders.SetMulti(tx, []*testOrder{{ID: 1, UserID: 1}, {ID: 2, UserID: 1}, {ID: 3, UserID: 2}})
+	_ = items.Set(tx, &testItem{ID: 1, OrderID: 2})
+
+	// the item restricts deleting its order, so neither the cascaded
+	// orders nor the user are deleted
+	if err := users.Del(tx, IntKey(1)); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Fatalf("Table.Del() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+	if _, err := users.Get(tx, IntKey(1)); err != nil {
+		t.Errorf("Table.Get() of restricted entry error = %v", err)
+	}
+	if n, _ := orders.Select(tx).Count(); n != 3 {
+		t.Errorf("TableLister.Count() of cascaded entries = %v, want 3", n)
+	}
+
+	if err := users.Del(tx, IntKey(2)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := orders.Select(tx).Count(); n != 2 {
+		t.Errorf("TableLister.Count() after cascade = %v, want 2", n)
+	}
+}
+
+func TestTable_References_commit(t *testing.T) {
+	db, users, orders := makeTestOrderDB(t, RefCascade)
+	tx1, tx2 := db.WriteTx(), db.WriteTx()
+	_ = users.Del(tx1, IntKey(5))
+	_ = orders.Set(tx2, &testOrder{ID: 5, UserID: 5})
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx1.Commit(); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Errorf("Txn.Commit() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+
+	// change sets include entries deleted by actions
+	tx := db.WriteTx()
+	_ = users.Del(tx, IntKey(1))
+	cs := tx.Changes()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(cs.Changes(orders)); n != 2 {
+		t.Errorf("ChangeSet.Changes() of orders = %v changes, want 2", n)
+	}
+}
+
+func TestTable_References_init(t *testing.T) {
+	_, users, orders := makeTestOrderDB(t, RefRestrict)
+	if _, err := Init(orders); err == nil {
+		t.Errorf("Init() without referenced table error = nil")
+	}
+	if _, err := Init(orders, users); err != nil {
+		t.Errorf("Init() error = %v", err)
+	}
+}
+
+func TestDB_ApplyChangeSet_references(t *testing.T) {
+	db, users, orders := makeTestOrderDB(t, RefRestrict)
+	tx := db.WriteTx()
+	_ = orders.Set(tx, &testOrder{ID: 5, UserID: 5})
+	cs := tx.Changes()
+	tx.Abort()
+	tx = db.WriteTx()
+	_ = users.Del(tx, IntKey(5))
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ApplyChangeSet(cs); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Errorf("DB.ApplyChangeSet() error = %v, want %v", err, ErrForeignKeyViolation)
+	}
+	if _, err := orders.Get(db.ReadTx(), IntKey(5)); err != ErrNotFound {
+		t.Errorf("Table.Get() of rejected entry error = %v, want %v", err, ErrNotFound)
+	}
+}
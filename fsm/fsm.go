@@ -0,0 +1,120 @@
+// Package fsm adapts a memdb database to a finite state machine driven
+// by a consensus log, such as Raft.
+//
+// Writes are not committed to the database directly. Instead, changes
+// of a write transaction are encoded into a command with Command and
+// proposed to the log, and every replica applies committed commands
+// in log order with Apply. The database of each replica then goes
+// through the same versions, numbered by log indexes. Entries are
+// replaced as a whole, so concurrent commands modifying the same entry
+// resolve to the one applied last.
+//
+// The methods match the Apply, Snapshot and Restore methods of common
+// Raft libraries; an adapter only needs to pass the index and the data
+// of log entries, and the snapshot sink and source.
+package fsm
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/knobz-io/memdb"
+)
+
+var ErrEmptyCommand = errors.New("fsm: transaction has no changes")
+
+// FSM applies commands of the log to a database.
+type FSM struct {
+	db *memdb.DB
+	mu sync.Mutex
+}
+
+// New returns an FSM applying commands to the database. The database
+// should not be written to other than by the FSM.
+func New(db *memdb.DB) *FSM {
+	return &FSM{db: db}
+}
+
+// Command encodes changes of the write transaction into a command and
+// aborts the transaction.
+func (f *FSM) Command(tx *memdb.Txn) ([]byte, error) {
+	defer tx.Abort()
+	cs := tx.Changes()
+	if cs.Len() == 0 {
+		return nil, ErrEmptyCommand
+	}
+	return f.db.EncodeChangeSet(cs)
+}
+
+// Apply commits the command stored in the log under given index. The
+// committed version of the database is the index, so commands which
+// the database already includes, e.g. after restoring a snapshot, are
+// skipped. The version advances to the index for empty commands too.
+// Commands violating unique indexes or foreign keys given the commands
+// applied before them are rejected with memdb.ErrUniqueViolation or
+// memdb.ErrForeignKeyViolation, the same way on every replica, and
+// the version advances to the index without any changes.
+func (f *FSM) Apply(index uint64, cmd []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	opts := memdb.ApplyOptions{AllowGaps: true}
+	cs := &memdb.ChangeSet{}
+	var err error
+	if len(cmd) > 0 {
+		cs, err = f.db.DecodeChangeSet(cmd)
+	}
+	if err == nil {
+		cs.Version = index
+		err = f.db.ApplyChangeSetWithOptions(cs, opts)
+	}
+	if err != nil {
+		// the version follows the log even if the command fails
+		if aerr := f.db.ApplyChangeSetWithOptions(&memdb.ChangeSet{Version: index}, opts); aerr != nil {
+			return aerr
+		}
+		return err
+	}
+	return nil
+}
+
+// Snapshot captures the current state of the database. It is cheap,
+// so it can be called while commands are being applied; the snapshot
+// is written by Persist afterwards.
+func (f *FSM) Snapshot() (*Snapshot, error) {
+	return &Snapshot{db: f.db, tx: f.db.ReadTx()}, nil
+}
+
+// Restore replaces the state of the database with a snapshot written
+// by Snapshot.Persist. The version of the database becomes the index
+// of the snapshot, even if it is lower than the current one, so the
+// commands following it are applied again. Databases with a write-ahead
+// log or checkpoints can not go back to a lower index and fail with
+// memdb.ErrVersionRewind.
+func (f *FSM) Restore(r io.Reader) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.db.Load(r)
+}
+
+// Snapshot is a point-in-time state of the database.
+type Snapshot struct {
+	db *memdb.DB
+	tx *memdb.Txn
+}
+
+// Index returns the index of the last command included in the
+// snapshot.
+func (s *Snapshot) Index() uint64 {
+	return s.tx.Version()
+}
+
+// Persist writes the snapshot to w.
+func (s *Snapshot) Persist(w io.Writer) error {
+	return s.db.SaveTx(s.tx, w)
+}
+
+// Release releases the snapshot.
+func (s *Snapshot) Release() {
+	s.tx.Abort()
+}
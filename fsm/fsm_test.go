@@ -0,0 +1,283 @@
+package fsm
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/knobz-io/memdb"
+)
+
+type testAccount struct {
+	ID      int
+	Balance int
+}
+
+type testAccountTable struct {
+	memdb.Table[*testAccount]
+	balance *memdb.IntIndex[*testAccount]
+}
+
+func makeTestReplica(t *testing.T) (*FSM, *memdb.DB, testAccountTable) {
+	t.Helper()
+	table := memdb.NewTable(func(a *testAccount) memdb.Key {
+		return memdb.IntKey(a.ID)
+	}, memdb.WithName[*testAccount]("accounts"))
+	table, balance := table.IndexInt(func(a *testAccount) int {
+		return a.Balance
+	})
+	accounts := testAccountTable{Table: table, balance: balance}
+	db, err := memdb.Init(accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(db), db, accounts
+}
+
+// fakeLog is an in-process consensus log. Entries without data stand
+// for entries which are not commands, e.g. configuration changes.
+type fakeLog struct {
+	entries [][]byte
+}
+
+func (l *fakeLog) append(data []byte) {
+	l.entries = append(l.entries, data)
+}
+
+// apply applies entries following given index to the FSM.
+func (l *fakeLog) apply(t *testing.T, f *FSM, from uint64) {
+	t.Helper()
+	for i := from; i < uint64(len(l.entries)); i++ {
+		if l.entries[i] == nil {
+			continue
+		}
+		if err := f.Apply(i+1, l.entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func listTestAccounts(t *testing.T, db *memdb.DB, accounts testAccountTable) []*testAccount {
+	t.Helper()
+	list, err := accounts.Select(db.ReadTx()).OrderBy(accounts.balance.Asc()).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return list
+}
+
+func TestFSM(t *testing.T) {
+	log := &fakeLog{}
+	leader, leaderDB, accounts := makeTestReplica(t)
+	propose := func(fn func(tx *memdb.Txn)) {
+		tx := leaderDB.WriteTx()
+		fn(tx)
+		cmd, err := leader.Command(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.append(cmd)
+	}
+	propose(func(tx *memdb.Txn) {
+		_ = accounts.SetMulti(tx, []*testAccount{{ID: 1, Balance: 10}, {ID: 2, Balance: 20}})
+	})
+	log.append(nil)
+	propose(func(tx *memdb.Txn) {
+		_ = accounts.Set(tx, &testAccount{ID: 3, Balance: 5})
+	})
+	log.apply(t, leader, 0)
+	propose(func(tx *memdb.Txn) {
+		_ = accounts.Del(tx, memdb.IntKey(1))
+		_ = accounts.Set(tx, &testAccount{ID: 2, Balance: 1})
+	})
+	log.apply(t, leader, 3)
+	if leaderDB.Version() != 4 {
+		t.Errorf("DB.Version() = %v, want 4", leaderDB.Version())
+	}
+	want := []*testAccount{{ID: 2, Balance: 1}, {ID: 3, Balance: 5}}
+	if got := listTestAccounts(t, leaderDB, accounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts = %+v, want %+v", got, want)
+	}
+
+	// a replica applying the log goes through the same states
+	follower, followerDB, followerAccounts := makeTestReplica(t)
+	log.apply(t, follower, 0)
+	if followerDB.Version() != leaderDB.Version() {
+		t.Errorf("DB.Version() of replica = %v, want %v", followerDB.Version(), leaderDB.Version())
+	}
+	if got := listTestAccounts(t, followerDB, followerAccounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts of replica = %+v, want %+v", got, want)
+	}
+	// applying entries again does nothing
+	log.apply(t, follower, 0)
+	if got := listTestAccounts(t, followerDB, followerAccounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts of replica = %+v, want %+v", got, want)
+	}
+
+	tx := leaderDB.WriteTx()
+	if _, err := leader.Command(tx); err != ErrEmptyCommand {
+		t.Errorf("FSM.Command() error = %v, want %v", err, ErrEmptyCommand)
+	}
+	if err := leader.Apply(5, []byte("invalid")); !errors.Is(err, memdb.ErrInvalidChangeSet) {
+		t.Errorf("FSM.Apply() error = %v, want %v", err, memdb.ErrInvalidChangeSet)
+	}
+}
+
+func TestFSM_Snapshot(t *testing.T) {
+	log := &fakeLog{}
+	leader, leaderDB, accounts := makeTestReplica(t)
+	for i := 1; i <= 6; i++ {
+		tx := leaderDB.WriteTx()
+		_ = accounts.Set(tx, &testAccount{ID: i % 4, Balance: i})
+		cmd, err := leader.Command(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.append(cmd)
+		if err := leader.Apply(uint64(i), cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snap, err := leader.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snap.Release()
+	// commands applied after the snapshot is taken are not included
+	tx := leaderDB.WriteTx()
+	_ = accounts.Set(tx, &testAccount{ID: 10, Balance: 100})
+	cmd, _ := leader.Command(tx)
+	log.append(cmd)
+	log.apply(t, leader, 6)
+
+	if snap.Index() != 6 {
+		t.Errorf("Snapshot.Index() = %v, want 6", snap.Index())
+	}
+	var buf bytes.Buffer
+	if err := snap.Persist(&buf); err != nil {
+		t.Fatal(err)
+	}
+	follower, followerDB, followerAccounts := makeTestReplica(t)
+	if err := follower.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if followerDB.Version() != 6 {
+		t.Errorf("DB.Version() after Restore = %v, want 6", followerDB.Version())
+	}
+	log.apply(t, follower, snap.Index())
+	want := listTestAccounts(t, leaderDB, accounts)
+	if got := listTestAccounts(t, followerDB, followerAccounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts of restored replica = %+v, want %+v", got, want)
+	}
+	if followerDB.Version() != leaderDB.Version() {
+		t.Errorf("DB.Version() of restored replica = %v, want %v", followerDB.Version(), leaderDB.Version())
+	}
+}
+
+func TestFSM_Apply_unique(t *testing.T) {
+	table := memdb.NewTable(func(a *testAccount) memdb.Key {
+		return memdb.IntKey(a.ID)
+	})
+	table, _ = table.IndexIntUnique(func(a *testAccount) int {
+		return a.Balance
+	})
+	replicas := make([]*FSM, 2)
+	dbs := make([]*memdb.DB, 2)
+	for i := range replicas {
+		db, err := memdb.Init(table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		replicas[i], dbs[i] = New(db), db
+	}
+	// both commands are built from the same state
+	cmds := make([][]byte, 2)
+	for i := range cmds {
+		tx := dbs[0].WriteTx()
+		if err := table.Set(tx, &testAccount{ID: i + 1, Balance: 100}); err != nil {
+			t.Fatal(err)
+		}
+		cmd, err := replicas[0].Command(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmds[i] = cmd
+	}
+	for i, f := range replicas {
+		if err := f.Apply(1, cmds[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Apply(2, cmds[1]); !errors.Is(err, memdb.ErrUniqueViolation) {
+			t.Errorf("FSM.Apply() error = %v, want %v", err, memdb.ErrUniqueViolation)
+		}
+		if n, _ := table.Select(dbs[i].ReadTx()).Count(); n != 1 {
+			t.Errorf("TableLister.Count() of replica %d = %v, want 1", i, n)
+		}
+		// the rejected command still takes its index
+		if v := dbs[i].Version(); v != 2 {
+			t.Errorf("DB.Version() of replica %d = %v, want 2", i, v)
+		}
+	}
+}
+
+func TestFSM_Apply_empty(t *testing.T) {
+	f, db, accounts := makeTestReplica(t)
+	tx := db.WriteTx()
+	_ = accounts.Set(tx, &testAccount{ID: 1, Balance: 10})
+	cmd, err := f.Command(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, _ := db.EncodeChangeSet(&memdb.ChangeSet{})
+	for i, c := range [][]byte{cmd, nil, empty} {
+		if err := f.Apply(uint64(i+1), c); err != nil {
+			t.Fatal(err)
+		}
+		if v := db.Version(); v != uint64(i+1) {
+			t.Errorf("DB.Version() = %v, want %v", v, i+1)
+		}
+	}
+}
+
+func TestFSM_Restore_older(t *testing.T) {
+	log := &fakeLog{}
+	leader, leaderDB, accounts := makeTestReplica(t)
+	var snap bytes.Buffer
+	for i := 1; i <= 6; i++ {
+		tx := leaderDB.WriteTx()
+		_ = accounts.Set(tx, &testAccount{ID: i % 4, Balance: i})
+		cmd, err := leader.Command(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log.append(cmd)
+		log.apply(t, leader, uint64(i-1))
+		if i == 4 {
+			s, _ := leader.Snapshot()
+			if err := s.Persist(&snap); err != nil {
+				t.Fatal(err)
+			}
+			s.Release()
+		}
+	}
+
+	// a replica which is ahead of the snapshot goes back to its index
+	// and applies the following commands again
+	follower, followerDB, followerAccounts := makeTestReplica(t)
+	log.apply(t, follower, 0)
+	if err := follower.Restore(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if v := followerDB.Version(); v != 4 {
+		t.Errorf("DB.Version() after Restore = %v, want 4", v)
+	}
+	log.apply(t, follower, 4)
+	want := listTestAccounts(t, leaderDB, accounts)
+	if got := listTestAccounts(t, followerDB, followerAccounts); !reflect.DeepEqual(got, want) {
+		t.Errorf("accounts of restored replica = %+v, want %+v", got, want)
+	}
+	if followerDB.Version() != 6 {
+		t.Errorf("DB.Version() of restored replica = %v, want 6", followerDB.Version())
+	}
+}
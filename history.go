@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"sort"
+	"time"
+)
+
+// Version returns the version of the database seen by the transaction.
+// Once a write transaction is committed, it returns the version which
+// includes its changes.
+func (tx *Txn) Version() uint64 {
+	return tx.version
+}
+
+// Version returns the latest committed version of the database.
+func (db *DB) Version() uint64 {
+	return db.load().version
+}
+
+// ReadTxAt starts a read transaction seeing the database as it was
+// at given version. Past versions are available as long as they are
+// retained according to HistorySize and HistoryAge options.
+func (db *DB) ReadTxAt(version uint64) (*Txn, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if head := db.load(); head.version == version {
+		return db.txAt(head, false, nil), nil
+	}
+	i := sort.Search(len(db.history), func(i int) bool {
+		return db.history[i].version >= version
+	})
+	if i == len(db.history) || db.history[i].version != version {
+		return nil, ErrVersionNotFound
+	}
+	return db.txAt(db.history[i], false, nil), nil
+}
+
+// ReadTxAsOf starts a read transaction seeing the database as it was
+// at given time.
+func (db *DB) ReadTxAsOf(t time.Time) (*Txn, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	head := db.load()
+	if !t.Before(head.time) {
+		return db.txAt(head, false, nil), nil
+	}
+	// find the last version committed before the time
+	i := sort.Search(len(db.history), func(i int) bool {
+		return db.history[i].time.After(t)
+	})
+	if i == 0 {
+		return nil, ErrVersionNotFound
+	}
+	return db.txAt(db.history[i-1], false, nil), nil
+}
+
+// retain adds the root replaced at given time to the history and
+// drops versions which are out of the retention window. It must be
+// called with the commit lock held.
+func (db *DB) retain(root *dbRoot, replaced time.Time) {
+	size, age := db.opts.HistorySize, db.opts.HistoryAge
+	if size <= 0 && age <= 0 {
+		return
+	}
+	db.history = append(db.history, root)
+	n := 0
+	if size > 0 && len(db.history) > size {
+		n = len(db.history) - size
+	}
+	if age > 0 {
+		// a version is needed until the one following it is older
+		// than the window
+		deadline := replaced.Add(-age)
+		for n < len(db.history)-1 && db.history[n+1].time.Before(deadline) {
+			n++
+		}
+	}
+	if n > 0 {
+		copy(db.history, db.history[n:])
+		for i := len(db.history) - n; i < len(db.history); i++ {
+			db.history[i] = nil
+		}
+		db.history = db.history[:len(db.history)-n]
+	}
+}
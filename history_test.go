@@ -0,0 +1,97 @@
+package memdb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDB_ReadTxAt(t *testing.T) {
+	db, users := openTestUserDB(t, Options{HistorySize: 2})
+	versions := []uint64{}
+	for i := 1; i <= 4; i++ {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 1, Status: i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		versions = append(versions, tx.Version())
+	}
+	if got := db.Version(); got != versions[3] {
+		t.Fatalf("DB.Version() = %v, want %v", got, versions[3])
+	}
+	if got := db.ReadTx().Version(); got != versions[3] {
+		t.Fatalf("Txn.Version() = %v, want %v", got, versions[3])
+	}
+	tests := []struct {
+		version uint64
+		status  int
+		err     error
+	}{
+		{version: versions[0], err: ErrVersionNotFound},
+		{version: versions[1], status: 2},
+		{version: versions[2], status: 3},
+		{version: versions[3], status: 4},
+		{version: versions[3] + 1, err: ErrVersionNotFound},
+	}
+	for _, tt := range tests {
+		tx, err := db.ReadTxAt(tt.version)
+		if err != tt.err {
+			t.Errorf("DB.ReadTxAt(%v) error = %v, want %v", tt.version, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if tx.Version() != tt.version {
+			t.Errorf("Txn.Version() = %v, want %v", tx.Version(), tt.version)
+		}
+		usr, err := users.Get(tx, IntKey(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usr.Status != tt.status {
+			t.Errorf("Table.Get() at version %v status = %v, want %v", tt.version, usr.Status, tt.status)
+		}
+		if err := users.Set(tx, usr); err != ErrTxnReadOnly {
+			t.Errorf("Table.Set() error = %v, want %v", err, ErrTxnReadOnly)
+		}
+	}
+}
+
+func TestDB_ReadTxAsOf(t *testing.T) {
+	db, users := openTestUserDB(t, Options{HistoryAge: time.Hour})
+	start := time.Now()
+	times := []time.Time{}
+	for i := 1; i <= 3; i++ {
+		time.Sleep(time.Millisecond)
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 1, Status: i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		times = append(times, time.Now())
+	}
+	if _, err := db.ReadTxAsOf(start.Add(-time.Hour)); err != ErrVersionNotFound {
+		t.Errorf("DB.ReadTxAsOf() error = %v, want %v", err, ErrVersionNotFound)
+	}
+	tx, err := db.ReadTxAsOf(start)
+	if err != nil {
+		t.Fatalf("DB.ReadTxAsOf() error = %v", err)
+	}
+	if _, err := users.Get(tx, IntKey(1)); err != ErrNotFound {
+		t.Errorf("Table.Get() error = %v, want %v", err, ErrNotFound)
+	}
+	for i, at := range times {
+		tx, err := db.ReadTxAsOf(at)
+		if err != nil {
+			t.Fatalf("DB.ReadTxAsOf() error = %v", err)
+		}
+		usr, err := users.Get(tx, IntKey(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usr.Status != i+1 {
+			t.Errorf("Table.Get() status = %v, want %v", usr.Status, i+1)
+		}
+	}
+}
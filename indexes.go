@@ -21,6 +21,7 @@ type Index[V any] interface {
 }
 
 type StringIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) string
 }
 
@@ -64,7 +65,14 @@ func (f *StringIndex[V]) GreaterThanOrEqual(v string) *GreaterThanOrEqualCond[V]
 
 func (f *StringIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *StringIndex[V]) Get(tx *Txn, v string) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, StringKey(v))
+}
+
 type IntIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) int
 }
 
@@ -88,6 +96,12 @@ func (f *IntIndex[V]) Desc() *OrderRule[V] {
 
 func (f *IntIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *IntIndex[V]) Get(tx *Txn, v int) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, IntKey(v))
+}
+
 func (f *IntIndex[V]) Is(v int) *EqualCond[V] {
 	return &EqualCond[V]{f, IntKey(v)}
 }
@@ -109,6 +123,7 @@ func (f *IntIndex[V]) IsGreaterThanOrEqual(v int) *GreaterThanOrEqualCond[V] {
 }
 
 type FloatIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) float64
 }
 
@@ -152,10 +167,31 @@ func (f *FloatIndex[V]) GreaterThanOrEqual(v float64) *GreaterThanOrEqualCond[V]
 
 func (f *FloatIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *FloatIndex[V]) Get(tx *Txn, v float64) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, FloatKey(v))
+}
+
 type BoolIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) bool
 }
 
+func (f *BoolIndex[V]) Asc() *OrderRule[V] {
+	return &OrderRule[V]{
+		index: f,
+		dir:   Asc,
+	}
+}
+
+func (f *BoolIndex[V]) Desc() *OrderRule[V] {
+	return &OrderRule[V]{
+		index: f,
+		dir:   Desc,
+	}
+}
+
 func (f *BoolIndex[V]) KeyOf(v V) Key {
 	return BoolKey(f.fn(v))
 }
@@ -170,7 +206,14 @@ func (f *BoolIndex[V]) IsFalse() *EqualCond[V] {
 
 func (f *BoolIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *BoolIndex[V]) Get(tx *Txn, v bool) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, BoolKey(v))
+}
+
 type BinaryIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) []byte
 }
 
@@ -194,6 +237,12 @@ func (f *BinaryIndex[V]) KeyOf(v V) Key {
 
 func (f *BinaryIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *BinaryIndex[V]) Get(tx *Txn, v []byte) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, BinaryKey(v))
+}
+
 func (f *BinaryIndex[V]) Is(v []byte) *EqualCond[V] {
 	return &EqualCond[V]{f, BinaryKey(v)}
 }
@@ -215,15 +264,36 @@ func (f *BinaryIndex[V]) GreaterThanOrEqual(v []byte) *GreaterThanOrEqualCond[V]
 }
 
 type CombinedIndex[V any] struct {
+	indexRef[V]
 	fn func(v V) CombinedKey
 }
 
+func (f *CombinedIndex[V]) Asc() *OrderRule[V] {
+	return &OrderRule[V]{
+		index: f,
+		dir:   Asc,
+	}
+}
+
+func (f *CombinedIndex[V]) Desc() *OrderRule[V] {
+	return &OrderRule[V]{
+		index: f,
+		dir:   Desc,
+	}
+}
+
 func (f *CombinedIndex[V]) KeyOf(v V) Key {
 	return f.fn(v)
 }
 
 func (f *CombinedIndex[V]) field() {}
 
+// Get returns the entry with given key in the index. If the index is
+// not unique, it returns the one with the lowest primary key.
+func (f *CombinedIndex[V]) Get(tx *Txn, k CombinedKey) (V, error) {
+	return lookup[V](tx, f, &f.indexRef, k)
+}
+
 func (f *CombinedIndex[V]) Is(k CombinedKey) *EqualCond[V] {
 	return &EqualCond[V]{f, k}
 }
@@ -0,0 +1,82 @@
+package memdb
+
+type TxOption func(tx *Txn)
+
+// Serializable makes the write transaction track the entries it reads
+// with Get and the index ranges scanned by its queries. Commit fails
+// with ErrConflict if any transaction committed since this one started
+// has modified the entries read or written entries matching the ranges.
+func Serializable() TxOption {
+	return func(tx *Txn) {
+		tx.reads = map[interface{}]*readSet{}
+	}
+}
+
+// readSet describes the part of a table read by a transaction.
+type readSet struct {
+	keys  *treeTxn[struct{}]
+	preds []func(v interface{}) bool
+}
+
+func (tx *Txn) readSet(ref interface{}) *readSet {
+	if tx.reads == nil || !tx.write {
+		return nil
+	}
+	rs, ok := tx.reads[ref]
+	if !ok {
+		rs = &readSet{keys: makeTree[struct{}]().txn(true)}
+		tx.reads[ref] = rs
+	}
+	return rs
+}
+
+// readKey records read of the entry under primary key k.
+func (tx *Txn) readKey(ref interface{}, k []byte) {
+	if rs := tx.readSet(ref); rs != nil {
+		rs.keys.set(k, struct{}{})
+	}
+}
+
+// readWhere records read of all the entries matching given predicate.
+func (tx *Txn) readWhere(ref interface{}, pred func(v interface{}) bool) {
+	if rs := tx.readSet(ref); rs != nil {
+		rs.preds = append(rs.preds, pred)
+	}
+}
+
+// readConflicts reports whether any commit made since the transaction
+// has started modified entries read by the transaction.
+func (tx *Txn) readConflicts() bool {
+	if len(tx.reads) == 0 {
+		return false
+	}
+	for c := tx.root.next; c != nil; c = c.next {
+		for ref, theirs := range c.changes {
+			rs, ok := tx.reads[ref]
+			if !ok {
+				continue
+			}
+			cc := theirs.txn(false).cursor()
+			ok = cc.first()
+			for ok {
+				if _, has := rs.keys.get(cc.key()); has || rs.matches(cc.val()) {
+					return true
+				}
+				ok = cc.next()
+			}
+		}
+	}
+	return false
+}
+
+func (rs *readSet) matches(c *change) bool {
+	for _, pred := range rs.preds {
+		if c.existed && pred(c.prev) {
+			return true
+		}
+		if c.exists && pred(c.value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package memdb
+
+import (
+	"testing"
+)
+
+func TestSerializable(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []TxOption
+		// each of the transactions inserts given entry when the
+		// check passes
+		check  func(tx *Txn, users testUserTable) bool
+		insert [2]*testUser
+		want   error
+	}{
+		{
+			name: "range_snapshot",
+			check: func(tx *Txn, users testUserTable) bool {
+				n, _ := users.Select(tx).Where(users.status.Is(7)).Count()
+				return n == 0
+			},
+			insert: [2]*testUser{{ID: 10, Status: 7}, {ID: 11, Status: 7}},
+			want:   nil,
+		},
+		{
+			name: "range",
+			opts: []TxOption{Serializable()},
+			check: func(tx *Txn, users testUserTable) bool {
+				n, _ := users.Select(tx).Where(users.status.Is(7)).Count()
+				return n == 0
+			},
+			insert: [2]*testUser{{ID: 10, Status: 7}, {ID: 11, Status: 7}},
+			want:   ErrConflict,
+		},
+		{
+			name: "range_disjoint",
+			opts: []TxOption{Serializable()},
+			check: func(tx *Txn, users testUserTable) bool {
+				n, _ := users.Select(tx).Where(users.status.IsGreaterThan(6)).Count()
+				return n == 0
+			},
+			insert: [2]*testUser{{ID: 10, Status: 7}, {ID: 11, Status: 3}},
+			want:   nil,
+		},
+		{
+			name: "range_moved_out",
+			opts: []TxOption{Serializable()},
+			check: func(tx *Txn, users testUserTable) bool {
+				_, err := users.Select(tx).Where(users.status.Is(1)).All()
+				return err == nil
+			},
+			insert: [2]*testUser{{ID: 10, Status: 3}, {ID: 1, Status: 3}},
+			want:   ErrConflict,
+		},
+		{
+			name: "scan",
+			opts: []TxOption{Serializable()},
+			check: func(tx *Txn, users testUserTable) bool {
+				n, _ := users.Select(tx).Count()
+				return n < 10
+			},
+			insert: [2]*testUser{{ID: 10, Status: 7}, {ID: 11, Status: 3}},
+			want:   ErrConflict,
+		},
+		{
+			name: "get",
+			opts: []TxOption{Serializable()},
+			check: func(tx *Txn, users testUserTable) bool {
+				_, err := users.Get(tx, IntKey(11))
+				return err == ErrNotFound
+			},
+			insert: [2]*testUser{{ID: 10, Status: 7}, {ID: 11, Status: 3}},
+			want:   ErrConflict,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, users := makeTestUserDB(t, makeTestUsers()...)
+			tx1 := db.WriteTx(tt.opts...)
+			tx2 := db.WriteTx(tt.opts...)
+			for i, tx := range []*Txn{tx1, tx2} {
+				if !tt.check(tx, users) {
+					t.Fatalf("check of transaction %d failed", i+1)
+				}
+				if err := users.Set(tx, tt.insert[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tx2.Commit(); err != nil {
+				t.Fatalf("Txn.Commit() error = %v", err)
+			}
+			if err := tx1.Commit(); err != tt.want {
+				t.Errorf("Txn.Commit() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
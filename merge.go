@@ -0,0 +1,241 @@
+package memdb
+
+import (
+	"bytes"
+	"sort"
+)
+
+// ChangesSince returns the net changes committed after given version,
+// with the version of the latest commit. Changes can only be exported
+// since versions retained according to HistorySize and HistoryAge
+// options, or since the latest version.
+func (db *DB) ChangesSince(version uint64) (*ChangeSet, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.changesSince(version, db.load())
+}
+
+// changesSince merges changes of the commits following given version
+// up to the root. It must be called with the commit lock held.
+func (db *DB) changesSince(version uint64, to *dbRoot) (*ChangeSet, error) {
+	from := to
+	if to.version != version {
+		i := sort.Search(len(db.history), func(i int) bool {
+			return db.history[i].version >= version
+		})
+		if i == len(db.history) || db.history[i].version != version {
+			return nil, ErrVersionNotFound
+		}
+		from = db.history[i]
+	}
+	net := map[interface{}]*treeTxn[*change]{}
+	for r := from; r != to; r = r.next {
+		for ref, changes := range r.next.changes {
+			acc, ok := net[ref]
+			if !ok {
+				acc = makeTree[*change]().txn(true)
+				net[ref] = acc
+			}
+			c := changes.txn(false).cursor()
+			ok = c.first()
+			for ok {
+				mergeChange(acc, c.key(), c.val())
+				ok = c.next()
+			}
+		}
+	}
+	cs := &ChangeSet{
+		Version: to.version,
+		tables:  make(map[interface{}][]Change, len(net)),
+	}
+	for ref, changes := range net {
+		if changes.root != nil {
+			cs.tables[ref] = exportChanges(changes.commit())
+		}
+	}
+	return cs, nil
+}
+
+// Resolver decides the outcome of a merge conflict in a table, where
+// an entry has been changed both locally and remotely.
+type Resolver interface {
+	tableRef() interface{}
+	// resolve returns the change which wins the conflict, or false if
+	// the conflict can not be resolved.
+	resolve(local, remote Change) (winner, bool)
+}
+
+// winner is the outcome of a resolved conflict.
+type winner struct {
+	change Change
+	local  bool // local entry is kept as it is
+}
+
+type resolver[V any] struct {
+	ref *V
+	fn  func(local, remote Change) (winner, bool)
+}
+
+func (r resolver[V]) tableRef() interface{} {
+	return r.ref
+}
+
+func (r resolver[V]) resolve(local, remote Change) (winner, bool) {
+	return r.fn(local, remote)
+}
+
+// LastWriterWins resolves conflicts in the table in favour of the
+// entry with the greater key in given index, e.g. a modification
+// timestamp, keeping the local entry on ties. An entry which has been
+// modified wins over one which has been deleted.
+func (t Table[V]) LastWriterWins(ts Index[V]) Resolver {
+	return resolver[V]{ref: t.ref, fn: func(local, remote Change) (winner, bool) {
+		if w, ok := keepModified(local, remote); ok {
+			return w, true
+		}
+		lk := ts.KeyOf(local.Value.(V)).Bytes()
+		rk := ts.KeyOf(remote.Value.(V)).Bytes()
+		if bytes.Compare(rk, lk) > 0 {
+			return winner{change: remote}, true
+		}
+		return winner{change: local, local: true}, true
+	}}
+}
+
+// MergeWith resolves conflicts in the table with the entry returned
+// by fn for the local and the remote entry. An entry which has been
+// modified wins over one which has been deleted.
+func (t Table[V]) MergeWith(fn func(local, remote V) V) Resolver {
+	return resolver[V]{ref: t.ref, fn: func(local, remote Change) (winner, bool) {
+		if w, ok := keepModified(local, remote); ok {
+			return w, true
+		}
+		merged := remote
+		merged.Value = fn(local.Value.(V), remote.Value.(V))
+		return winner{change: merged}, true
+	}}
+}
+
+// RejectConflicts makes merges with conflicts in the table fail. It is
+// the default for tables without a resolver.
+func (t Table[V]) RejectConflicts() Resolver {
+	return resolver[V]{ref: t.ref, fn: func(local, remote Change) (winner, bool) {
+		return winner{}, false
+	}}
+}
+
+// keepModified resolves conflicts where one of the sides has deleted
+// the entry.
+func keepModified(local, remote Change) (winner, bool) {
+	if local.Kind == ChangeDelete {
+		return winner{change: remote}, true
+	}
+	if remote.Kind == ChangeDelete {
+		return winner{change: local, local: true}, true
+	}
+	return winner{}, false
+}
+
+// MergeConflict describes an entry changed both locally and remotely.
+// Local and Remote hold the conflicting values, nil for deletes, and
+// Merged the value the entry has been set to, if the conflict was
+// resolved.
+type MergeConflict struct {
+	Table    string
+	Key      []byte
+	Local    interface{}
+	Remote   interface{}
+	Merged   interface{}
+	Resolved bool
+}
+
+type MergeResult struct {
+	// Version is the version of the database which includes merged
+	// changes.
+	Version   uint64
+	Conflicts []MergeConflict
+}
+
+// Merge applies changes of another database, e.g. exported from it
+// with ChangesSince, in a single commit. Entries changed both by the
+// remote changes and by local commits made after given version are
+// conflicts, resolved with the resolver of their table. If any of
+// them can not be resolved, nothing is changed and ErrMergeConflict
+// is returned. The result lists all the conflicts in both cases.
+// Change sets of databases initialized with other Table values must be
+// transferred with EncodeChangeSet and DecodeChangeSet.
+func (db *DB) Merge(remote *ChangeSet, since uint64, resolvers ...Resolver) (*MergeResult, error) {
+	tx := db.WriteTx()
+	defer tx.Abort()
+	if err := tx.check(true); err != nil {
+		return nil, err
+	}
+	db.mu.Lock()
+	local, err := db.changesSince(since, tx.root)
+	db.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	byRef := make(map[interface{}]Resolver, len(resolvers))
+	for _, r := range resolvers {
+		byRef[r.tableRef()] = r
+	}
+	res := &MergeResult{}
+	rejected := false
+	for _, t := range db.tables {
+		ref := t.tableRef()
+		changes, ok := remote.tables[ref]
+		if !ok {
+			continue
+		}
+		locals := make(map[string]Change, len(local.tables[ref]))
+		for _, c := range local.tables[ref] {
+			locals[string(c.Key)] = c
+		}
+		for _, c := range changes {
+			l, conflict := locals[string(c.Key)]
+			if conflict && l.Kind == ChangeDelete && c.Kind == ChangeDelete {
+				continue
+			}
+			if conflict {
+				mc := MergeConflict{
+					Table:  db.names[ref],
+					Key:    c.Key,
+					Local:  l.Value,
+					Remote: c.Value,
+				}
+				var won winner
+				if r, ok := byRef[ref]; ok {
+					won, mc.Resolved = r.resolve(l, c)
+				}
+				if mc.Resolved {
+					mc.Merged = won.change.Value
+				}
+				res.Conflicts = append(res.Conflicts, mc)
+				if !mc.Resolved {
+					rejected = true
+					continue
+				}
+				if won.local {
+					continue
+				}
+				c = won.change
+			}
+			ch := &change{value: c.Value, exists: c.Kind != ChangeDelete}
+			if err := db.replayfn[ref](tx, c.Key, ch); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if rejected {
+		return res, ErrMergeConflict
+	}
+	if err := db.validate(tx); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	res.Version = tx.Version()
+	return res, nil
+}
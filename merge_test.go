@@ -0,0 +1,170 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+// makeTestMergeDBs returns two databases sharing the users table, both
+// changed after version 1.
+func makeTestMergeDBs(t *testing.T) (device, server *DB, users testUserTable) {
+	t.Helper()
+	users = makeTestUserTable()
+	device, err := InitWithOptions(Options{HistorySize: 10}, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err = InitWithOptions(Options{HistorySize: 10}, users)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, db := range []*DB{device, server} {
+		tx := db.WriteTx()
+		_ = users.SetMulti(tx, makeTestUsers())
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit := func(db *DB, fn func(tx *Txn)) {
+		tx := db.WriteTx()
+		fn(tx)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	commit(device, func(tx *Txn) {
+		_ = users.Set(tx, &testUser{ID: 1, Status: 5, Name: "Device"})
+		_ = users.Set(tx, &testUser{ID: 2, Status: 1, Name: "Device"})
+	})
+	commit(device, func(tx *Txn) {
+		_ = users.Del(tx, IntKey(3))
+		_ = users.Set(tx, &testUser{ID: 6, Name: "Device"})
+	})
+	commit(server, func(tx *Txn) {
+		_ = users.Set(tx, &testUser{ID: 1, Status: 3, Name: "Server"})
+		_ = users.Set(tx, &testUser{ID: 2, Status: 9, Name: "Server"})
+		_ = users.Set(tx, &testUser{ID: 3, Status: 7, Name: "Server"})
+	})
+	return device, server, users
+}
+
+func TestDB_Merge(t *testing.T) {
+	device, server, users := makeTestMergeDBs(t)
+	exported, err := device.ChangesSince(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.Version != device.Version() {
+		t.Errorf("ChangeSet.Version = %v, want %v", exported.Version, device.Version())
+	}
+	data, err := device.EncodeChangeSet(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote, err := server.DecodeChangeSet(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// conflicts are rejected by default
+	res, err := server.Merge(remote, 1)
+	if err != ErrMergeConflict {
+		t.Fatalf("DB.Merge() error = %v, want %v", err, ErrMergeConflict)
+	}
+	if len(res.Conflicts) != 3 {
+		t.Errorf("MergeResult.Conflicts = %+v, want 3 conflicts", res.Conflicts)
+	}
+	if server.Version() != 2 {
+		t.Errorf("DB.Version() after rejected merge = %v, want 2", server.Version())
+	}
+
+	res, err = server.Merge(remote, 1, users.LastWriterWins(users.status))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Version != server.Version() || res.Version != 3 {
+		t.Errorf("MergeResult.Version = %v, want 3", res.Version)
+	}
+	wantConflicts := []MergeConflict{
+		{
+			Table:    "*memdb.testUser",
+			Key:      IntKey(1).Bytes(),
+			Local:    &testUser{ID: 1, Status: 3, Name: "Server"},
+			Remote:   &testUser{ID: 1, Status: 5, Name: "Device"},
+			Merged:   &testUser{ID: 1, Status: 5, Name: "Device"},
+			Resolved: true,
+		},
+		{
+			Table:    "*memdb.testUser",
+			Key:      IntKey(2).Bytes(),
+			Local:    &testUser{ID: 2, Status: 9, Name: "Server"},
+			Remote:   &testUser{ID: 2, Status: 1, Name: "Device"},
+			Merged:   &testUser{ID: 2, Status: 9, Name: "Server"},
+			Resolved: true,
+		},
+		{
+			Table:    "*memdb.testUser",
+			Key:      IntKey(3).Bytes(),
+			Local:    &testUser{ID: 3, Status: 7, Name: "Server"},
+			Merged:   &testUser{ID: 3, Status: 7, Name: "Server"},
+			Resolved: true,
+		},
+	}
+	if !reflect.DeepEqual(res.Conflicts, wantConflicts) {
+		t.Errorf("MergeResult.Conflicts = %+v, want %+v", res.Conflicts, wantConflicts)
+	}
+	list, _ := users.Select(server.ReadTx()).All()
+	want := []*testUser{
+		{ID: 1, Status: 5, Name: "Device"},
+		{ID: 2, Status: 9, Name: "Server"},
+		{ID: 3, Status: 7, Name: "Server"},
+		makeTestUsers()[3],
+		makeTestUsers()[4],
+		{ID: 6, Name: "Device"},
+	}
+	if !reflect.DeepEqual(list, want) {
+		t.Errorf("TableLister.All() after merge = %+v, want %+v", list, want)
+	}
+}
+
+func TestDB_Merge_func(t *testing.T) {
+	device, server, users := makeTestMergeDBs(t)
+	remote, err := device.ChangesSince(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = server.Merge(remote, 1, users.MergeWith(func(local, remote *testUser) *testUser {
+		return &testUser{ID: local.ID, Status: local.Status + remote.Status, Name: local.Name + "+" + remote.Name}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	usr, err := users.Get(server.ReadTx(), IntKey(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&testUser{ID: 2, Status: 10, Name: "Server+Device"}); !reflect.DeepEqual(usr, want) {
+		t.Errorf("Table.Get() after merge = %+v, want %+v", usr, want)
+	}
+}
+
+func TestDB_ChangesSince(t *testing.T) {
+	device, _, users := makeTestMergeDBs(t)
+	cs, err := device.ChangesSince(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Kind: ChangeDelete, Key: IntKey(3).Bytes(), Prev: makeTestUsers()[2]},
+		{Kind: ChangeInsert, Key: IntKey(6).Bytes(), Value: &testUser{ID: 6, Name: "Device"}},
+	}
+	if got := cs.Changes(users); !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangeSet.Changes() = %+v, want %+v", got, want)
+	}
+	if cs, err := device.ChangesSince(device.Version()); err != nil || cs.Len() != 0 {
+		t.Errorf("DB.ChangesSince(latest) = %+v, %v, want no changes", cs, err)
+	}
+	if _, err := device.ChangesSince(device.Version() + 1); err != ErrVersionNotFound {
+		t.Errorf("DB.ChangesSince() error = %v, want %v", err, ErrVersionNotFound)
+	}
+}
@@ -1,5 +1,11 @@
 package memdb
 
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+)
+
 type OrderDirection int
 
 const (
@@ -11,3 +17,178 @@ type OrderRule[V any] struct {
 	index Index[V]
 	dir   OrderDirection
 }
+
+// groupCursor iterates over primary keys sharing the same key of
+// the first order rule. Keys are sorted by the remaining rules and
+// then by the primary key in given direction.
+//
+// Sorting by the remaining rules needs their keys of all the entries
+// of the group, so entering a group costs time linear in its size.
+// The entries are sorted lazily, as they are visited, so taking a few
+// of them does not sort the whole group. Queries ordered by multiple
+// rules whose first rule has few distinct keys are better served by
+// a single IndexMultiple index of all the rules.
+type groupCursor[V any] struct {
+	c    *treeCursor[struct{}]
+	dir  OrderDirection
+	intr *interrupter
+	// rest holds entries not visited yet as a heap, sorted holds the
+	// visited ones in order
+	rest   groupHeap[V]
+	sorted []groupEntry
+	pos    int
+}
+
+type groupEntry struct {
+	id   []byte
+	keys [][]byte
+}
+
+// makeGroupCursor returns a cursor over the group. Reading the group
+// stops early once intr is interrupted, leaving the cursor over the
+// entries read so far.
+func makeGroupCursor[V any](idx *treeTxn[V], sub *tree[struct{}], rules []*OrderRule[V], dir OrderDirection, intr *interrupter) *groupCursor[V] {
+	g := &groupCursor[V]{
+		c:    sub.txn(false).cursor(),
+		dir:  dir,
+		intr: intr,
+	}
+	if len(rules) == 0 {
+		return g
+	}
+	g.rest = groupHeap[V]{rules: rules, dir: dir}
+	ok := g.c.first()
+	for ok && !intr.interrupted() {
+		v, _ := idx.get(g.c.key())
+		e := groupEntry{id: g.c.key(), keys: make([][]byte, len(rules))}
+		for i, r := range rules {
+			e.keys[i] = r.index.KeyOf(v).Bytes()
+		}
+		g.rest.entries = append(g.rest.entries, e)
+		ok = g.c.next()
+	}
+	heap.Init(&g.rest)
+	g.c = nil
+	return g
+}
+
+// fill sorts entries up to position i, reporting whether it exists.
+func (g *groupCursor[V]) fill(i int) bool {
+	for len(g.sorted) <= i && g.rest.Len() > 0 {
+		g.sorted = append(g.sorted, heap.Pop(&g.rest).(groupEntry))
+	}
+	return i >= 0 && i < len(g.sorted)
+}
+
+func (g *groupCursor[V]) key() []byte {
+	if g.c != nil {
+		return g.c.key()
+	}
+	return g.sorted[g.pos].id
+}
+
+func (g *groupCursor[V]) first() bool {
+	if g.c != nil {
+		if g.dir == Asc {
+			return g.c.first()
+		}
+		return g.c.last()
+	}
+	g.pos = 0
+	return g.fill(g.pos)
+}
+
+func (g *groupCursor[V]) last() bool {
+	if g.c != nil {
+		if g.dir == Asc {
+			return g.c.last()
+		}
+		return g.c.first()
+	}
+	// the remaining entries are sorted at once
+	rest := g.rest.entries
+	sort.Slice(rest, func(i, j int) bool {
+		return g.rest.less(rest[i], rest[j])
+	})
+	g.sorted = append(g.sorted, rest...)
+	g.rest.entries = nil
+	g.pos = len(g.sorted) - 1
+	return g.pos >= 0
+}
+
+func (g *groupCursor[V]) next() bool {
+	if g.c != nil {
+		if g.dir == Asc {
+			return g.c.next()
+		}
+		return g.c.prev()
+	}
+	if g.pos < len(g.sorted) {
+		g.pos++
+	}
+	return g.fill(g.pos)
+}
+
+func (g *groupCursor[V]) prev() bool {
+	if g.c != nil {
+		if g.dir == Asc {
+			return g.c.prev()
+		}
+		return g.c.next()
+	}
+	if g.pos >= 0 {
+		g.pos--
+	}
+	return g.pos >= 0 && g.pos < len(g.sorted)
+}
+
+// seek moves the cursor to the given primary key.
+func (g *groupCursor[V]) seek(k []byte) bool {
+	if g.c != nil {
+		return g.c.ceil(k) && bytes.Equal(g.c.key(), k)
+	}
+	for i := 0; !g.intr.interrupted() && g.fill(i); i++ {
+		if bytes.Equal(g.sorted[i].id, k) {
+			g.pos = i
+			return true
+		}
+	}
+	return false
+}
+
+// groupHeap orders entries of a group by the keys of the order rules,
+// breaking ties by the primary key in given direction.
+type groupHeap[V any] struct {
+	entries []groupEntry
+	rules   []*OrderRule[V]
+	dir     OrderDirection
+}
+
+func (h *groupHeap[V]) less(a, b groupEntry) bool {
+	for n, r := range h.rules {
+		cmp := bytes.Compare(a.keys[n], b.keys[n])
+		if cmp == 0 {
+			continue
+		}
+		if r.dir == Desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	}
+	cmp := bytes.Compare(a.id, b.id)
+	if h.dir == Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func (h *groupHeap[V]) Len() int           { return len(h.entries) }
+func (h *groupHeap[V]) Less(i, j int) bool { return h.less(h.entries[i], h.entries[j]) }
+func (h *groupHeap[V]) Swap(i, j int)      { h.entries[i], h.entries[j] = h.entries[j], h.entries[i] }
+func (h *groupHeap[V]) Push(x interface{}) { h.entries = append(h.entries, x.(groupEntry)) }
+
+func (h *groupHeap[V]) Pop() interface{} {
+	e := h.entries[len(h.entries)-1]
+	h.entries = h.entries[:len(h.entries)-1]
+	return e
+}
@@ -0,0 +1,143 @@
+package replication
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/knobz-io/memdb"
+)
+
+var errTrailingData = errors.New("replication: snapshot has trailing data")
+
+// Follower applies change sets streamed by a leader to a database,
+// which should be initialized with the ReadOnly option and the same
+// tables as the database of the leader.
+type Follower struct {
+	db *memdb.DB
+}
+
+func NewFollower(db *memdb.DB) *Follower {
+	return &Follower{db: db}
+}
+
+// Run replicates the database of the leader connected over conn until
+// the connection fails or ctx is done. The connection is closed when
+// it returns. After a failure, Run can be called again with a new
+// connection to resume from the last applied version.
+func (f *Follower) Run(ctx context.Context, conn net.Conn) error {
+	defer conn.Close()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-stop:
+		}
+	}()
+	var hello [8]byte
+	binary.BigEndian.PutUint64(hello[:], f.db.Version())
+	w := bufio.NewWriter(conn)
+	if err := writeMsg(w, msgHello, hello[:]); err != nil {
+		return f.err(ctx, err)
+	}
+	r := bufio.NewReader(conn)
+	var snap *snapshotLoader
+	defer func() {
+		if snap != nil {
+			snap.abort()
+		}
+	}()
+	for {
+		typ, payload, err := readMsg(r)
+		if err != nil {
+			return f.err(ctx, err)
+		}
+		if snap != nil && typ != msgSnapshot {
+			return unexpected(typ)
+		}
+		switch typ {
+		case msgSnapshot:
+			if snap == nil {
+				snap = f.load()
+			}
+			if len(payload) > 0 {
+				err = snap.write(payload)
+				break
+			}
+			err = snap.close()
+			snap = nil
+		case msgChangeSet:
+			var cs *memdb.ChangeSet
+			cs, err = f.db.DecodeChangeSet(payload)
+			if err == nil {
+				// versions of the leader skip ahead when it applies
+				// change sets or loads snapshots of newer versions
+				err = f.db.ApplyChangeSetWithOptions(cs, memdb.ApplyOptions{AllowGaps: true})
+			}
+		default:
+			err = unexpected(typ)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// err returns the error of ctx if the connection failed because it
+// was closed when ctx was done.
+func (f *Follower) err(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// snapshotLoader loads a snapshot into the database while its parts
+// are received.
+type snapshotLoader struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error // error of Load, set once done is closed
+}
+
+// load starts loading a snapshot into the database.
+func (f *Follower) load() *snapshotLoader {
+	pr, pw := io.Pipe()
+	l := &snapshotLoader{pw: pw, done: make(chan struct{})}
+	go func() {
+		l.err = f.db.Load(pr)
+		close(l.done)
+		// parts written after Load has returned fail
+		pr.CloseWithError(errTrailingData)
+	}()
+	return l
+}
+
+func (l *snapshotLoader) write(p []byte) error {
+	if _, err := l.pw.Write(p); err != nil {
+		<-l.done
+		if l.err != nil {
+			return l.err
+		}
+		return err
+	}
+	return nil
+}
+
+// close ends the snapshot and waits until it is loaded.
+func (l *snapshotLoader) close() error {
+	l.pw.Close()
+	<-l.done
+	return l.err
+}
+
+// abort stops loading the snapshot without changing the database.
+func (l *snapshotLoader) abort() {
+	l.pw.CloseWithError(io.ErrUnexpectedEOF)
+	<-l.done
+}
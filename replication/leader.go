@@ -0,0 +1,236 @@
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"sync"
+
+	"github.com/knobz-io/memdb"
+)
+
+const (
+	DefaultBacklog = 1024
+	DefaultBuffer  = 1024
+)
+
+type LeaderOptions struct {
+	// Backlog is the number of the latest change sets kept for
+	// followers resuming replication. Zero means DefaultBacklog.
+	Backlog int
+	// Buffer is the number of change sets queued for a follower
+	// before it is disconnected for not keeping up. Zero means
+	// DefaultBuffer.
+	Buffer int
+}
+
+// Leader streams change sets committed to a database to followers.
+type Leader struct {
+	db      *memdb.DB
+	sub     *memdb.Subscription
+	backlog int
+	buffer  int
+
+	mu        sync.Mutex
+	records   []record
+	base      uint64 // records hold all the commits after base
+	last      uint64 // version of the last record
+	followers map[*peer]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+type record struct {
+	version uint64
+	data    []byte
+}
+
+// peer is a connected follower.
+type peer struct {
+	after uint64 // version the follower has
+	ch    chan []byte
+	err   error // reason the peer was dropped
+}
+
+// NewLeader starts streaming change sets of the database. Followers
+// are served with ServeConn or Serve.
+func NewLeader(db *memdb.DB, opts LeaderOptions) *Leader {
+	if opts.Backlog <= 0 {
+		opts.Backlog = DefaultBacklog
+	}
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultBuffer
+	}
+	// the leader must see every commit, so commits wait for it rather
+	// than closing its subscription when it falls behind
+	sub := db.WatchWithOptions(memdb.WatchOptions{Buffer: 64, Policy: memdb.WatchBlock})
+	l := &Leader{
+		db:        db,
+		sub:       sub,
+		backlog:   opts.Backlog,
+		buffer:    opts.Buffer,
+		followers: map[*peer]struct{}{},
+	}
+	// commits made before the subscription are not in the backlog
+	l.base = db.Version()
+	l.last = l.base
+	l.wg.Add(1)
+	go l.run()
+	return l
+}
+
+func (l *Leader) run() {
+	defer l.wg.Done()
+	for cs := range l.sub.C() {
+		data, err := l.db.EncodeChangeSet(cs)
+		l.mu.Lock()
+		if cs.Version <= l.last {
+			l.mu.Unlock()
+			continue
+		}
+		if err != nil {
+			// followers can not be kept consistent, so they are
+			// dropped and start over from a snapshot
+			l.records = l.records[:0]
+			l.base, l.last = cs.Version, cs.Version
+			for p := range l.followers {
+				l.drop(p, err)
+			}
+			l.mu.Unlock()
+			continue
+		}
+		l.records = append(l.records, record{version: cs.Version, data: data})
+		if len(l.records) > l.backlog {
+			l.base = l.records[0].version
+			l.records[0] = record{}
+			l.records = l.records[1:]
+		}
+		l.last = cs.Version
+		for p := range l.followers {
+			if cs.Version <= p.after {
+				continue
+			}
+			select {
+			case p.ch <- data:
+			default:
+				l.drop(p, ErrLagging)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// drop disconnects the follower. It must be called with the lock held.
+func (l *Leader) drop(p *peer, err error) {
+	delete(l.followers, p)
+	p.err = err
+	close(p.ch)
+}
+
+// Serve accepts connections of followers and serves each of them in
+// a new goroutine, until the listener fails.
+func (l *Leader) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			_ = l.ServeConn(conn)
+		}()
+	}
+}
+
+// ServeConn streams change sets to the follower connected over conn,
+// until the connection fails, the follower does not keep up or the
+// leader is closed. The connection is closed when it returns.
+func (l *Leader) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+	typ, payload, err := readMsg(conn)
+	if err != nil {
+		return err
+	}
+	if typ != msgHello || len(payload) != 8 {
+		return unexpected(typ)
+	}
+	version := binary.BigEndian.Uint64(payload)
+	p, tx, err := l.attach(version)
+	if err != nil {
+		return err
+	}
+	defer l.detach(p)
+	w := bufio.NewWriter(conn)
+	if tx != nil {
+		// the snapshot is streamed over the connection rather than
+		// taken into memory first
+		sw := &snapshotWriter{w: w, buf: make([]byte, 0, snapshotChunkSize)}
+		if err := l.db.SaveTx(tx, sw); err != nil {
+			return err
+		}
+		if err := sw.close(); err != nil {
+			return err
+		}
+	}
+	for data := range p.ch {
+		if err := writeMsg(w, msgChangeSet, data); err != nil {
+			return err
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return p.err
+}
+
+// attach registers the follower having given version. If the backlog
+// does not hold all the commits the follower misses, it returns a read
+// transaction the snapshot for the follower is taken from.
+func (l *Leader) attach(version uint64) (*peer, *memdb.Txn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return nil, nil, ErrClosed
+	}
+	p := &peer{after: version, ch: make(chan []byte, l.buffer+len(l.records))}
+	var tx *memdb.Txn
+	switch {
+	case version > l.db.Version():
+		return nil, nil, ErrAhead
+	case version >= l.base:
+		for _, r := range l.records {
+			if r.version > version {
+				p.ch <- r.data
+			}
+		}
+	default:
+		// change sets up to the version of the snapshot are skipped
+		tx = l.db.ReadTx()
+		p.after = tx.Version()
+	}
+	l.followers[p] = struct{}{}
+	return p, tx, nil
+}
+
+func (l *Leader) detach(p *peer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.followers[p]; ok {
+		delete(l.followers, p)
+	}
+}
+
+// Close stops streaming and disconnects all the followers.
+func (l *Leader) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	for p := range l.followers {
+		l.drop(p, ErrClosed)
+	}
+	l.mu.Unlock()
+	l.sub.Close()
+	l.wg.Wait()
+	return nil
+}
@@ -0,0 +1,128 @@
+// Package replication keeps read-only replicas of a memdb database up
+// to date. A Leader streams change sets committed to its database to
+// followers connected over any net.Conn, and a Follower applies them
+// in commit order to its own database.
+//
+// A follower which connects with a version of the database the leader
+// still keeps the following change sets of resumes from it, otherwise
+// it receives a full snapshot first.
+package replication
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Message layout: type byte, uint32 big-endian size of the payload
+// and the payload.
+const (
+	// msgHello is sent by the follower when it connects, with the
+	// version of its database as the payload.
+	msgHello byte = iota + 1
+	// msgSnapshot carries the next part of a snapshot written with
+	// DB.SaveTx. A message without payload ends the snapshot.
+	msgSnapshot
+	// msgChangeSet carries a change set encoded with
+	// DB.EncodeChangeSet.
+	msgChangeSet
+)
+
+// MaxMessageSize is the largest payload accepted from a peer.
+// Snapshots are sent in parts of snapshotChunkSize, so only change
+// sets are limited by it.
+const MaxMessageSize = 1 << 30
+
+const snapshotChunkSize = 64 << 10
+
+var (
+	ErrClosed          = errors.New("replication: leader is closed")
+	ErrLagging         = errors.New("replication: follower did not keep up with the leader")
+	ErrAhead           = errors.New("replication: follower is ahead of the leader")
+	ErrMessageTooLarge = errors.New("replication: message exceeds the maximum size")
+)
+
+func writeMsg(w *bufio.Writer, typ byte, payload []byte) error {
+	if len(payload) > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+	var header [5]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func readMsg(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := int64(binary.BigEndian.Uint32(header[1:]))
+	if size > MaxMessageSize {
+		return 0, nil, ErrMessageTooLarge
+	}
+	// the payload grows with the bytes actually received, so a peer
+	// cannot make it allocate memory by sending a large size alone
+	payload, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return 0, nil, err
+	}
+	if int64(len(payload)) < size {
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return header[0], payload, nil
+}
+
+func unexpected(typ byte) error {
+	return fmt.Errorf("replication: unexpected message %d", typ)
+}
+
+// snapshotWriter sends data written to it as msgSnapshot messages of
+// up to snapshotChunkSize bytes.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (s *snapshotWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		k := snapshotChunkSize - len(s.buf)
+		if k > len(p) {
+			k = len(p)
+		}
+		s.buf = append(s.buf, p[:k]...)
+		p = p[k:]
+		if len(s.buf) == snapshotChunkSize {
+			if err := s.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (s *snapshotWriter) flush() error {
+	if len(s.buf) == 0 {
+		return nil
+	}
+	err := writeMsg(s.w, msgSnapshot, s.buf)
+	s.buf = s.buf[:0]
+	return err
+}
+
+// close sends the remaining data and ends the snapshot.
+func (s *snapshotWriter) close() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return writeMsg(s.w, msgSnapshot, nil)
+}
@@ -0,0 +1,219 @@
+package replication
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/knobz-io/memdb"
+)
+
+type testItem struct {
+	ID    int
+	Group string
+}
+
+type testItemTable struct {
+	memdb.Table[*testItem]
+	group *memdb.StringIndex[*testItem]
+}
+
+func makeTestItemTable() testItemTable {
+	table := memdb.NewTable(func(it *testItem) memdb.Key {
+		return memdb.IntKey(it.ID)
+	}, memdb.WithName[*testItem]("items"))
+	table, group := table.IndexString(func(it *testItem) string {
+		return it.Group
+	})
+	return testItemTable{Table: table, group: group}
+}
+
+func makeTestDB(t *testing.T, opts memdb.Options) (*memdb.DB, testItemTable) {
+	t.Helper()
+	items := makeTestItemTable()
+	db, err := memdb.InitWithOptions(opts, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db, items
+}
+
+func commitTestItems(t *testing.T, db *memdb.DB, items testItemTable, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		tx := db.WriteTx()
+		_ = items.Set(tx, &testItem{ID: i, Group: []string{"a", "b"}[i%2]})
+		if i > 3 {
+			_ = items.Del(tx, memdb.IntKey(i-3))
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// follow runs a follower over an in-memory connection, returning a
+// function which stops it and returns its error.
+func follow(l *Leader, f *Follower) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	lc, fc := net.Pipe()
+	go func() {
+		_ = l.ServeConn(lc)
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- f.Run(ctx, fc)
+	}()
+	return func() error {
+		cancel()
+		return <-errc
+	}
+}
+
+func waitTestReplica(t *testing.T, leader *memdb.DB, items testItemTable, replica *memdb.DB, replicaItems testItemTable) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for replica.Version() != leader.Version() {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica version = %v, want %v", replica.Version(), leader.Version())
+		}
+		time.Sleep(time.Millisecond)
+	}
+	for _, group := range []string{"a", "b"} {
+		want, _ := items.Select(leader.ReadTx()).Where(items.group.Is(group)).All()
+		got, _ := replicaItems.Select(replica.ReadTx()).Where(replicaItems.group.Is(group)).All()
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("replica items of group %v = %+v, want %+v", group, got, want)
+		}
+	}
+}
+
+func TestReplication(t *testing.T) {
+	tests := []struct {
+		name    string
+		backlog int
+	}{
+		{"resume", 0},
+		{"snapshot", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, items := makeTestDB(t, memdb.Options{})
+			commitTestItems(t, db, items, 1, 5)
+			l := NewLeader(db, LeaderOptions{Backlog: tt.backlog})
+			defer l.Close()
+
+			replica, replicaItems := makeTestDB(t, memdb.Options{ReadOnly: true})
+			f := NewFollower(replica)
+			stop := follow(l, f)
+			commitTestItems(t, db, items, 6, 10)
+			waitTestReplica(t, db, items, replica, replicaItems)
+			if err := stop(); err != context.Canceled {
+				t.Errorf("Follower.Run() error = %v, want %v", err, context.Canceled)
+			}
+
+			// the follower catches up after reconnecting
+			commitTestItems(t, db, items, 11, 15)
+			stop = follow(l, f)
+			waitTestReplica(t, db, items, replica, replicaItems)
+			commitTestItems(t, db, items, 16, 20)
+			waitTestReplica(t, db, items, replica, replicaItems)
+			_ = stop()
+
+			tx := replica.WriteTx()
+			if err := replicaItems.Set(tx, &testItem{ID: 100}); err != memdb.ErrTxnReadOnly {
+				t.Errorf("Table.Set() on replica error = %v, want %v", err, memdb.ErrTxnReadOnly)
+			}
+		})
+	}
+}
+
+func TestLeader_ahead(t *testing.T) {
+	db, _ := makeTestDB(t, memdb.Options{})
+	l := NewLeader(db, LeaderOptions{})
+	defer l.Close()
+	replica, items := makeTestDB(t, memdb.Options{})
+	commitTestItems(t, replica, items, 1, 2)
+
+	lc, fc := net.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.ServeConn(lc)
+	}()
+	go func() {
+		_ = NewFollower(replica).Run(context.Background(), fc)
+	}()
+	if err := <-errc; err != ErrAhead {
+		t.Errorf("Leader.ServeConn() error = %v, want %v", err, ErrAhead)
+	}
+}
+
+func TestLeader_Close(t *testing.T) {
+	db, items := makeTestDB(t, memdb.Options{})
+	l := NewLeader(db, LeaderOptions{})
+	replica, replicaItems := makeTestDB(t, memdb.Options{ReadOnly: true})
+	lc, fc := net.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- l.ServeConn(lc)
+	}()
+	go func() {
+		_ = NewFollower(replica).Run(context.Background(), fc)
+	}()
+	commitTestItems(t, db, items, 1, 3)
+	waitTestReplica(t, db, items, replica, replicaItems)
+	_ = l.Close()
+	if err := <-errc; err != ErrClosed {
+		t.Errorf("Leader.ServeConn() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+func TestFollower_messageTooLarge(t *testing.T) {
+	replica, _ := makeTestDB(t, memdb.Options{ReadOnly: true})
+	lc, fc := net.Pipe()
+	defer lc.Close()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- NewFollower(replica).Run(context.Background(), fc)
+	}()
+	if _, _, err := readMsg(lc); err != nil {
+		t.Fatal(err)
+	}
+	// only the header of a message claiming a payload of 4 GiB is sent
+	header := []byte{msgSnapshot, 0xff, 0xff, 0xff, 0xff}
+	if _, err := lc.Write(header); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errc; err != ErrMessageTooLarge {
+		t.Errorf("Follower.Run() error = %v, want %v", err, ErrMessageTooLarge)
+	}
+}
+
+func TestReplication_largeSnapshot(t *testing.T) {
+	db, items := makeTestDB(t, memdb.Options{})
+	tx := db.WriteTx()
+	for i := 1; i <= 3000; i++ {
+		_ = items.Set(tx, &testItem{ID: i, Group: []string{"a", "b"}[i%2]})
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := db.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() <= snapshotChunkSize {
+		t.Fatalf("snapshot size = %v, want more than %v", buf.Len(), snapshotChunkSize)
+	}
+	l := NewLeader(db, LeaderOptions{})
+	defer l.Close()
+	replica, replicaItems := makeTestDB(t, memdb.Options{ReadOnly: true})
+	stop := follow(l, NewFollower(replica))
+	defer stop()
+	waitTestReplica(t, db, items, replica, replicaItems)
+	commitTestItems(t, db, items, 3001, 3002)
+	waitTestReplica(t, db, items, replica, replicaItems)
+}
@@ -0,0 +1,94 @@
+package memdb
+
+import (
+	"errors"
+	"unsafe"
+)
+
+// Savepoint is a state of a transaction which can be restored with
+// Txn.RollbackTo. As trees of the transaction are immutable, taking
+// a savepoint only copies their roots.
+type Savepoint struct {
+	tx       *Txn
+	tm       map[interface{}]map[uint8]unsafe.Pointer
+	changes  map[interface{}]*tree[*change]
+	onCommit int
+	onAbort  int
+}
+
+// Savepoint captures the current state of the transaction.
+func (tx *Txn) Savepoint() *Savepoint {
+	sp := &Savepoint{
+		tx:       tx,
+		tm:       make(map[interface{}]map[uint8]unsafe.Pointer, len(tx.tm)),
+		changes:  make(map[interface{}]*tree[*change], len(tx.changes)),
+		onCommit: len(tx.onCommit),
+		onAbort:  len(tx.onAbort),
+	}
+	for ref, v := range tx.tm {
+		sp.tm[ref] = make(map[uint8]unsafe.Pointer, len(v))
+		for j, p := range v {
+			sp.tm[ref][j] = tx.db.commitfn[ref][j](p)
+		}
+	}
+	for ref, changes := range tx.changes {
+		sp.changes[ref] = changes.commit()
+	}
+	return sp
+}
+
+// RollbackTo discards all the changes made to tables of the transaction
+// since the savepoint was taken, together with hooks registered since.
+// The savepoint stays valid, so the transaction can be rolled back to
+// it again.
+func (tx *Txn) RollbackTo(sp *Savepoint) error {
+	if sp == nil || sp.tx != tx {
+		return errors.New("memdb: savepoint does not belong to the transaction")
+	}
+	if err := tx.check(false); err != nil {
+		return err
+	}
+	tm := make(map[interface{}]map[uint8]unsafe.Pointer, len(sp.tm))
+	for ref, v := range sp.tm {
+		tm[ref] = make(map[uint8]unsafe.Pointer, len(v))
+		for j, p := range v {
+			tm[ref][j] = tx.db.txfn[ref][j](p, tx.write)
+		}
+	}
+	changes := make(map[interface{}]*treeTxn[*change], len(sp.changes))
+	for ref, c := range sp.changes {
+		changes[ref] = c.txn(true)
+	}
+	tx.tm = tm
+	tx.changes = changes
+	if sp.onCommit < len(tx.onCommit) {
+		tx.onCommit = tx.onCommit[:sp.onCommit]
+	}
+	if sp.onAbort < len(tx.onAbort) {
+		tx.onAbort = tx.onAbort[:sp.onAbort]
+	}
+	return nil
+}
+
+// Sub runs fn as a nested transaction. If fn returns an error or
+// panics, all the changes it made are rolled back, leaving the rest
+// of the transaction intact.
+func (tx *Txn) Sub(fn func(tx *Txn) error) error {
+	if err := tx.check(false); err != nil {
+		return err
+	}
+	sp := tx.Savepoint()
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.RollbackTo(sp)
+			panic(r)
+		}
+	}()
+	if err := fn(tx); err != nil {
+		if rerr := tx.RollbackTo(sp); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	return nil
+}
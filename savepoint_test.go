@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTxn_RollbackTo(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx := db.WriteTx()
+	if err := users.Set(tx, &testUser{ID: 6, Status: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sp := tx.Savepoint()
+	if err := users.Set(tx, &testUser{ID: 7, Status: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Set(tx, &testUser{ID: 1, Status: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := users.Del(tx, IntKey(3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(sp); err != nil {
+		t.Fatalf("Txn.RollbackTo() error = %v", err)
+	}
+	if err := users.Set(tx, &testUser{ID: 8, Status: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.RollbackTo(db.WriteTx().Savepoint()); err == nil {
+		t.Errorf("Txn.RollbackTo() with savepoint of other transaction error = nil")
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := users.Select(db.ReadTx()).Where(users.status.Is(1)).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testUserIDs(list), []int{1, 3, 6, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TableLister.All() = %v, want %v", got, want)
+	}
+}
+
+func TestTxn_Sub(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	errBad := errors.New("bad record")
+	tx := db.WriteTx()
+	for _, usr := range []*testUser{{ID: 1}, {ID: 2, Status: -1}, {ID: 3}} {
+		err := tx.Sub(func(tx *Txn) error {
+			if err := users.Set(tx, usr); err != nil {
+				return err
+			}
+			if usr.Status < 0 {
+				return errBad
+			}
+			return nil
+		})
+		if err != nil && err != errBad {
+			t.Fatalf("Txn.Sub() error = %v", err)
+		}
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != errBad {
+				t.Fatalf("Txn.Sub() panic = %v, want %v", r, errBad)
+			}
+		}()
+		_ = tx.Sub(func(tx *Txn) error {
+			_ = users.Set(tx, &testUser{ID: 4})
+			panic(errBad)
+		})
+	}()
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	list, err := users.Select(db.ReadTx()).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := testUserIDs(list), []int{1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("TableLister.All() = %v, want %v", got, want)
+	}
+}
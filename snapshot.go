@@ -0,0 +1,306 @@
+package memdb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Snapshot layout, all integers big-endian:
+//
+//	magic   [8]byte "MEMDBSNP"
+//	format  uint32
+//	version uint64  version of the database the snapshot was taken at
+//	tables  uint32  number of tables
+//	for each table:
+//	    name    uvarint length followed by the name of the table
+//	    rows    uvarint length+1 followed by the row encoded with
+//	            the codec of the table, terminated by a zero length
+//	crc     uint32  Castagnoli checksum of all the preceding bytes
+const (
+	snapshotMagic  = "MEMDBSNP"
+	snapshotFormat = 1
+	maxRowSize     = 1 << 31
+	readChunkSize  = 64 << 10
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Save writes a snapshot of all the tables of the database, as seen by
+// a new read transaction, to w. Only primary rows are written, indexes
+// are rebuilt when the snapshot is loaded.
+func (db *DB) Save(w io.Writer) error {
+	return db.SaveTx(db.ReadTx(), w)
+}
+
+// SaveTx writes a snapshot of all the tables of the database, as seen
+// by given transaction, to w.
+func (db *DB) SaveTx(tx *Txn, w io.Writer) error {
+	if err := tx.check(false); err != nil {
+		return err
+	}
+	sw := newSnapshotWriter(w)
+	sw.write([]byte(snapshotMagic))
+	sw.uint32(snapshotFormat)
+	sw.uint64(tx.Version())
+	sw.uint32(uint32(len(db.tables)))
+	for _, t := range db.tables {
+		name := db.names[t.tableRef()]
+		sw.uvarint(uint64(len(name)))
+		sw.write([]byte(name))
+		err := t.encode(tx, func(b []byte) error {
+			sw.uvarint(uint64(len(b)) + 1)
+			sw.write(b)
+			return sw.err
+		})
+		if err != nil {
+			return err
+		}
+		sw.uvarint(0)
+	}
+	return sw.close()
+}
+
+// Load replaces the contents of all the tables with the rows of the
+// snapshot read from r, and commits them under the version the
+// snapshot was taken at, which becomes the version of the database.
+// Loading a snapshot of an older version drops the retained history,
+// and fails with ErrVersionRewind if the database has a write-ahead
+// log or checkpoints. Tables missing from the snapshot are emptied.
+// Nothing is changed if the snapshot is invalid. Load works on
+// read-only databases as well.
+func (db *DB) Load(r io.Reader) error {
+	tx := db.writeTx()
+	defer tx.Abort()
+	if err := db.loadSnapshot(tx, r); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadSnapshot applies the snapshot read from r to the write
+// transaction.
+func (db *DB) loadSnapshot(tx *Txn, r io.Reader) error {
+	sr := newSnapshotReader(r)
+	if !bytes.Equal(sr.read(len(snapshotMagic)), []byte(snapshotMagic)) && sr.err == nil {
+		return ErrInvalidSnapshot
+	}
+	format := sr.uint32()
+	if format != snapshotFormat && sr.err == nil {
+		return fmt.Errorf("memdb: unsupported snapshot format %d", format)
+	}
+	version := sr.uint64()
+	n := sr.uint32()
+	positions := make(map[string]int, len(db.tables))
+	for i, t := range db.tables {
+		positions[db.names[t.tableRef()]] = i
+	}
+	// rows are set as they are read, and the rows missing from the
+	// snapshot are deleted at the end
+	keep := make([]*treeTxn[struct{}], len(db.tables))
+	for i := range keep {
+		keep[i] = makeTree[struct{}]().txn(true)
+	}
+	for i := uint32(0); i < n && sr.err == nil; i++ {
+		size := sr.uvarint()
+		if size > maxRowSize {
+			return fmt.Errorf("%w: table name too long", ErrInvalidSnapshot)
+		}
+		name := string(sr.read(int(size)))
+		pos, ok := positions[name]
+		if sr.err == nil && !ok {
+			return fmt.Errorf("%w: unknown table %q", ErrInvalidSnapshot, name)
+		}
+		for sr.err == nil {
+			size := sr.uvarint()
+			if size == 0 {
+				break
+			}
+			if size > maxRowSize {
+				return fmt.Errorf("%w: row too large", ErrInvalidSnapshot)
+			}
+			b := sr.read(int(size - 1))
+			if sr.err != nil {
+				break
+			}
+			v, err := db.tables[pos].decode(b)
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+			}
+			if err := db.tables[pos].load(tx, v, keep[pos]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sr.close(); err != nil {
+		return err
+	}
+	for i, t := range db.tables {
+		if err := t.prune(tx, keep[i]); err != nil {
+			return err
+		}
+	}
+	tx.applied = version
+	tx.applyMode = applyExact
+	return nil
+}
+
+// snapshotWriter writes the snapshot and computes its checksum,
+// keeping the first error.
+type snapshotWriter struct {
+	w   *bufio.Writer
+	crc hash.Hash32
+	buf [binary.MaxVarintLen64]byte
+	err error
+}
+
+func newSnapshotWriter(w io.Writer) *snapshotWriter {
+	return &snapshotWriter{
+		w:   bufio.NewWriter(w),
+		crc: crc32.New(crcTable),
+	}
+}
+
+func (w *snapshotWriter) write(b []byte) {
+	if w.err != nil {
+		return
+	}
+	w.crc.Write(b)
+	_, w.err = w.w.Write(b)
+}
+
+func (w *snapshotWriter) uint32(v uint32) {
+	binary.BigEndian.PutUint32(w.buf[:4], v)
+	w.write(w.buf[:4])
+}
+
+func (w *snapshotWriter) uint64(v uint64) {
+	binary.BigEndian.PutUint64(w.buf[:8], v)
+	w.write(w.buf[:8])
+}
+
+func (w *snapshotWriter) uvarint(v uint64) {
+	n := binary.PutUvarint(w.buf[:], v)
+	w.write(w.buf[:n])
+}
+
+// close writes the checksum and flushes the buffered data.
+func (w *snapshotWriter) close() error {
+	if w.err != nil {
+		return w.err
+	}
+	binary.BigEndian.PutUint32(w.buf[:4], w.crc.Sum32())
+	if _, err := w.w.Write(w.buf[:4]); err != nil {
+		return err
+	}
+	return w.w.Flush()
+}
+
+// snapshotReader reads the snapshot and verifies its checksum,
+// keeping the first error. Unexpected end of data is reported as
+// ErrInvalidSnapshot.
+type snapshotReader struct {
+	r   *bufio.Reader
+	crc hash.Hash32
+	buf [8]byte
+	err error
+}
+
+func newSnapshotReader(r io.Reader) *snapshotReader {
+	return &snapshotReader{
+		r:   bufio.NewReader(r),
+		crc: crc32.New(crcTable),
+	}
+}
+
+func (r *snapshotReader) fail(err error) {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: unexpected end of data", ErrInvalidSnapshot)
+	}
+	r.err = err
+}
+
+func (r *snapshotReader) read(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	var b []byte
+	var err error
+	if n <= readChunkSize {
+		b = make([]byte, n)
+		_, err = io.ReadFull(r.r, b)
+	} else {
+		// sizes are read from the snapshot before its checksum is
+		// verified, so large buffers grow with the bytes actually read
+		b, err = io.ReadAll(io.LimitReader(r.r, int64(n)))
+		if err == nil && len(b) < n {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if err != nil {
+		r.fail(err)
+		return nil
+	}
+	r.crc.Write(b)
+	return b
+}
+
+func (r *snapshotReader) uint32() uint32 {
+	b := r.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+func (r *snapshotReader) uint64() uint64 {
+	b := r.read(8)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+func (r *snapshotReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		r.fail(err)
+		return 0
+	}
+	return v
+}
+
+// ReadByte implements io.ByteReader for reading varints.
+func (r *snapshotReader) ReadByte() (byte, error) {
+	c, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.buf[0] = c
+	r.crc.Write(r.buf[:1])
+	return c, nil
+}
+
+// close verifies the checksum.
+func (r *snapshotReader) close() error {
+	if r.err != nil {
+		return r.err
+	}
+	sum := r.crc.Sum32()
+	var b [4]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		r.fail(err)
+		return r.err
+	}
+	if binary.BigEndian.Uint32(b[:]) != sum {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	return nil
+}
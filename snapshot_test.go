@@ -0,0 +1,180 @@
+package memdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestDB_Save(t *testing.T) {
+	src, users := makeTestUserDB(t, makeTestUsers()...)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, dstUsers := makeTestUserDB(t, &testUser{ID: 1, Name: "Old"}, &testUser{ID: 9, Name: "Removed"})
+	if err := dst.Load(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	// the version of the snapshot replaces the version of the database
+	if got, want := dst.Version(), src.Version(); got != want {
+		t.Errorf("DB.Version() after Load = %v, want %v", got, want)
+	}
+	tx := dst.ReadTx()
+	list, err := dstUsers.Select(tx).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, makeTestUsers()) {
+		t.Errorf("TableLister.All() after Load = %+v, want %+v", list, makeTestUsers())
+	}
+	// indexes are rebuilt
+	list, err = dstUsers.Select(tx).Where(dstUsers.name.Is("Anna")).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := testUserIDs(list); !reflect.DeepEqual(got, []int{2, 5}) {
+		t.Errorf("TableLister.All() by index after Load = %v, want %v", got, []int{2, 5})
+	}
+	list, err = dstUsers.Select(tx).Where(dstUsers.name.Is("Old")).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("TableLister.All() of replaced rows = %+v, want none", list)
+	}
+
+	// versions of an empty database continue from the snapshot
+	for i := 0; i < 3; i++ {
+		tx := src.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 10 + i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf.Reset()
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	empty, _ := makeTestUserDB(t)
+	if err := empty.Load(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := empty.Version(), src.Version(); got != want {
+		t.Errorf("DB.Version() after Load = %v, want %v", got, want)
+	}
+}
+
+func TestDB_Load_invalid(t *testing.T) {
+	src, _ := makeTestUserDB(t, makeTestUsers()...)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)/2] ^= 0xff
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"magic", append([]byte("NOTMEMDB"), data[8:]...)},
+		{"truncated", data[:len(data)-5]},
+		{"corrupted", corrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst, users := makeTestUserDB(t, &testUser{ID: 1, Name: "Old"})
+			version := dst.Version()
+			err := dst.Load(bytes.NewReader(tt.data))
+			if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("DB.Load() error = %v, want %v", err, ErrInvalidSnapshot)
+			}
+			if dst.Version() != version {
+				t.Errorf("DB.Version() = %v, want %v", dst.Version(), version)
+			}
+			usr, err := users.Get(dst.ReadTx(), IntKey(1))
+			if err != nil || usr.Name != "Old" {
+				t.Errorf("Table.Get() = %+v, %v, want unchanged entry", usr, err)
+			}
+		})
+	}
+}
+
+func TestDB_Load_largeRowSize(t *testing.T) {
+	src, _ := makeTestUserDB(t, makeTestUsers()...)
+	var buf bytes.Buffer
+	if err := src.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// the header and the name of the first table followed by a row
+	// claiming the maximum size, without its bytes
+	data := buf.Bytes()
+	data = append([]byte{}, data[:25+int(data[24])]...)
+	data = binary.AppendUvarint(data, maxRowSize)
+	dst, _ := makeTestUserDB(t)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := dst.Load(bytes.NewReader(data)); !errors.Is(err, ErrInvalidSnapshot) {
+		t.Errorf("DB.Load() error = %v, want %v", err, ErrInvalidSnapshot)
+	}
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n > 1<<20 {
+		t.Errorf("DB.Load() allocated %d bytes for a truncated row", n)
+	}
+}
+
+func TestDB_Load_version(t *testing.T) {
+	src, users := makeTestUserDB(t, makeTestUsers()...)
+	var old bytes.Buffer
+	if err := src.Save(&old); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		tx := src.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 10 + i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var cur bytes.Buffer
+	if err := src.Save(&cur); err != nil {
+		t.Fatal(err)
+	}
+
+	// a snapshot without changes sets the version as well
+	dst, _ := openTestUserDB(t, Options{HistorySize: 10})
+	for _, v := range []uint64{4, 4} {
+		if err := dst.Load(bytes.NewReader(cur.Bytes())); err != nil {
+			t.Fatal(err)
+		}
+		if dst.Version() != v {
+			t.Errorf("DB.Version() after Load = %v, want %v", dst.Version(), v)
+		}
+	}
+	// an older snapshot takes the database back to its version
+	if err := dst.Load(bytes.NewReader(old.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if dst.Version() != 1 {
+		t.Errorf("DB.Version() after Load = %v, want 1", dst.Version())
+	}
+	if _, err := dst.ReadTxAt(4); err != ErrVersionNotFound {
+		t.Errorf("DB.ReadTxAt() of dropped version error = %v, want %v", err, ErrVersionNotFound)
+	}
+
+	durable, _ := openTestUserDB(t, Options{WAL: &WALOptions{Path: filepath.Join(t.TempDir(), "wal")}})
+	defer durable.Close()
+	if err := durable.Load(bytes.NewReader(cur.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if err := durable.Load(bytes.NewReader(old.Bytes())); err != ErrVersionRewind {
+		t.Errorf("DB.Load() of older snapshot error = %v, want %v", err, ErrVersionRewind)
+	}
+}
@@ -3,75 +3,163 @@ package memdb
 import (
 	"bytes"
 	"errors"
-	"sync/atomic"
 	"unsafe"
 )
 
 type TableType interface {
 	registerTable(db *DB) error
+	tableRef() interface{}
+	tableName() string
 	table()
+	encode(tx *Txn, fn func(b []byte) error) error
+	marshal(v interface{}) ([]byte, error)
+	decode(b []byte) (interface{}, error)
+	load(tx *Txn, v interface{}, keep *treeTxn[struct{}]) error
+	prune(tx *Txn, keep *treeTxn[struct{}]) error
+	validate(tx *Txn) error
+	has(tx *Txn, k []byte) bool
 }
 
 type Table[V any] struct {
-	ref  *V
-	fn   KeyFunc[V]
-	idxm IndexMap[V]
-	cb   callbacks[V]
+	ref   *V
+	fn    KeyFunc[V]
+	idxm  IndexMap[V]
+	cb    callbacks[V]
+	name  string
+	codec Codec[V]
+	refs  []func(t Table[V]) (*reference, error)
 }
 
-func NewTable[V any](fn KeyFunc[V]) Table[V] {
-	return Table[V]{
+// TableOption configures a table created with NewTable.
+type TableOption[V any] func(t *Table[V])
+
+// WithName sets the name identifying the table in serialized data.
+// Tables without a name are named after the type of their values.
+func WithName[V any](name string) TableOption[V] {
+	return func(t *Table[V]) {
+		t.name = name
+	}
+}
+
+// WithCodec sets the codec used to serialize values of the table.
+// Tables use GobCodec by default.
+func WithCodec[V any](c Codec[V]) TableOption[V] {
+	return func(t *Table[V]) {
+		t.codec = c
+	}
+}
+
+func NewTable[V any](fn KeyFunc[V], opts ...TableOption[V]) Table[V] {
+	t := Table[V]{
 		ref: new(V),
 		fn:  fn,
 		idxm: IndexMap[V]{
 			m: make(map[Index[V]]int),
 		},
+		codec: GobCodec[V]{},
 	}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+// Name returns the name of the table set with WithName.
+func (t Table[V]) Name() string {
+	return t.name
+}
+
+// Codec returns the codec used to serialize values of the table.
+func (t Table[V]) Codec() Codec[V] {
+	return t.codec
 }
 
 func (t Table[V]) IndexString(fn func(V) string) (Table[V], *StringIndex[V]) {
-	f := &StringIndex[V]{fn}
-	t.idxm = t.idxm.add(f)
-	t = t.registerIndex(f)
-	return t, f
+	f := &StringIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexStringUnique is like IndexString, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexStringUnique(fn func(V) string) (Table[V], *StringIndex[V]) {
+	f := &StringIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
 }
 
 func (t Table[V]) IndexInt(fn func(V) int) (Table[V], *IntIndex[V]) {
-	f := &IntIndex[V]{fn}
-	t.idxm = t.idxm.add(f)
-	t = t.registerIndex(f)
-	return t, f
+	f := &IntIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexIntUnique is like IndexInt, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexIntUnique(fn func(V) int) (Table[V], *IntIndex[V]) {
+	f := &IntIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
 }
 
 func (t Table[V]) IndexFloat(fn func(V) float64) (Table[V], *FloatIndex[V]) {
-	f := &FloatIndex[V]{fn}
-	t.idxm = t.idxm.add(f)
-	t = t.registerIndex(f)
-	return t, f
+	f := &FloatIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexFloatUnique is like IndexFloat, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexFloatUnique(fn func(V) float64) (Table[V], *FloatIndex[V]) {
+	f := &FloatIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
 }
 
 func (t Table[V]) IndexBool(fn func(V) bool) (Table[V], *BoolIndex[V]) {
-	f := &BoolIndex[V]{fn}
-	t.idxm = t.idxm.add(f)
-	t = t.registerIndex(f)
-	return t, f
+	f := &BoolIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexBoolUnique is like IndexBool, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexBoolUnique(fn func(V) bool) (Table[V], *BoolIndex[V]) {
+	f := &BoolIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
 }
 
 func (t Table[V]) IndexBinary(fn func(V) []byte) (Table[V], *BinaryIndex[V]) {
-	f := &BinaryIndex[V]{fn}
-	t.idxm = t.idxm.add(f)
-	t = t.registerIndex(f)
-	return t, f
+	f := &BinaryIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexBinaryUnique is like IndexBinary, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexBinaryUnique(fn func(V) []byte) (Table[V], *BinaryIndex[V]) {
+	f := &BinaryIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
 }
 
 func (t Table[V]) IndexMultiple(fn func(V) CombinedKey) (Table[V], *CombinedIndex[V]) {
-	f := &CombinedIndex[V]{fn}
+	f := &CombinedIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, false), f
+}
+
+// IndexMultipleUnique is like IndexMultiple, but no two entries of the table
+// can share a key in the index.
+func (t Table[V]) IndexMultipleUnique(fn func(V) CombinedKey) (Table[V], *CombinedIndex[V]) {
+	f := &CombinedIndex[V]{fn: fn}
+	return t.addIndex(f, &f.indexRef, true), f
+}
+
+func (t Table[V]) addIndex(f Index[V], r *indexRef[V], unique bool) Table[V] {
+	*r = indexRef[V]{table: t.ref, pos: uint8(t.idxm.n + 1), unique: unique}
 	t.idxm = t.idxm.add(f)
 	t = t.registerIndex(f)
-	return t, f
+	if unique {
+		t = t.registerUnique(f, r)
+	}
+	return t
 }
 
-func (t Table[V]) data(tx *Txn) (*treeTxn[V], error) {
+func (t Table[V]) data(tx *Txn, write bool) (*treeTxn[V], error) {
+	if err := tx.check(write); err != nil {
+		return nil, err
+	}
 	p, ok := tx.tm[t.ref][0]
 	if !ok {
 		return nil, errors.New("memdb: table not found in transaction")
@@ -80,26 +168,66 @@ func (t Table[V]) data(tx *Txn) (*treeTxn[V], error) {
 }
 
 func (t Table[V]) Get(tx *Txn, id Key) (V, error) {
-	data, err := t.data(tx)
+	data, err := t.data(tx, false)
 	if err != nil {
 		return *new(V), err
 	}
-	v, ok := data.get(id.Bytes())
+	k := id.Bytes()
+	tx.readKey(t.ref, k)
+	v, ok := data.get(k)
 	if !ok {
 		return *new(V), ErrNotFound
 	}
 	return v, nil
 }
 
+// Set inserts or replaces the entry. It returns UniqueViolationError,
+// leaving the transaction unchanged, if the entry would share a key of
+// a unique index with another entry.
 func (t Table[V]) Set(tx *Txn, v V) error {
-	data, err := t.data(tx)
+	data, err := t.data(tx, true)
 	if err != nil {
 		return err
 	}
 	k := t.fn(v).Bytes()
+	if err := t.checkUnique(tx, k, v); err != nil {
+		return err
+	}
+	t.set(tx, data, k, v)
+	return nil
+}
+
+// SetMulti sets all the entries. If any of them violates a unique
+// index, none of them is set.
+func (t Table[V]) SetMulti(tx *Txn, vs []V) error {
+	data, err := t.data(tx, true)
+	if err != nil {
+		return err
+	}
+	var sp *Savepoint
+	if len(t.cb.checkfn) > 0 && len(vs) > 1 {
+		sp = tx.Savepoint()
+	}
+	for _, v := range vs {
+		k := t.fn(v).Bytes()
+		if err := t.checkUnique(tx, k, v); err != nil {
+			if sp != nil {
+				_ = tx.RollbackTo(sp)
+			}
+			return err
+		}
+		t.set(tx, data, k, v)
+	}
+	return nil
+}
+
+// set stores the entry under primary key k without checking unique
+// indexes.
+func (t Table[V]) set(tx *Txn, data *treeTxn[V], k []byte, v V) {
 	prev, ok := data.get(k)
 
 	data.set(k, v)
+	tx.track(t.ref, k, prev, ok, v, true)
 	if ok {
 		for _, fn := range t.cb.updfn {
 			fn(tx, v, prev)
@@ -109,66 +237,65 @@ func (t Table[V]) Set(tx *Txn, v V) error {
 			fn(tx, v)
 		}
 	}
-	return nil
 }
 
-func (t Table[V]) SetMulti(tx *Txn, vs []V) error {
-	data, err := t.data(tx)
+// DelMulti deletes entries with given primary keys. If deleting any
+// of them is restricted by a foreign key, none of them is deleted.
+func (t Table[V]) DelMulti(tx *Txn, pks []Key) error {
+	data, err := t.data(tx, true)
 	if err != nil {
 		return err
 	}
-	for _, v := range vs {
-		k := t.fn(v).Bytes()
-		prev, ok := data.get(k)
-
-		data.set(k, v)
-		if ok {
-			for _, fn := range t.cb.updfn {
-				fn(tx, v, prev)
-			}
-		} else {
-			for _, fn := range t.cb.setfn {
-				fn(tx, v)
+	var sp *Savepoint
+	if len(tx.db.refs[t.ref]) > 0 {
+		sp = tx.Savepoint()
+	}
+	for _, pk := range pks {
+		if err := t.delete(tx, data, pk.Bytes()); err != nil {
+			if sp != nil {
+				_ = tx.RollbackTo(sp)
 			}
+			return err
 		}
 	}
 	return nil
 }
 
-func (t Table[V]) DelMulti(tx *Txn, pks []Key) error {
-	data, err := t.data(tx)
-	if err != nil {
-		return err
+// Del deletes the entry with given primary key, applying actions of
+// foreign keys referencing the table to entries referencing it. It
+// returns ForeignKeyError, leaving the transaction unchanged, if the
+// entry is referenced by a foreign key with RefRestrict action.
+func (t Table[V]) Del(tx *Txn, pk Key) error {
+	return t.DelMulti(tx, []Key{pk})
+}
+
+// delete deletes the entry under primary key k together with applying
+// actions of foreign keys referencing it.
+func (t Table[V]) delete(tx *Txn, data *treeTxn[V], k []byte) error {
+	if !t.remove(tx, data, k) {
+		return nil
 	}
-	for _, pk := range pks {
-		k := pk.Bytes()
-		v, ok := data.get(k)
-		if !ok {
-			continue
-		}
-		data.del(k)
-		for _, fn := range t.cb.delfn {
-			fn(tx, v)
+	for _, r := range tx.db.refs[t.ref] {
+		if err := r.deleted(tx, k); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-func (t Table[V]) Del(tx *Txn, pk Key) error {
-	data, err := t.data(tx)
-	if err != nil {
-		return err
-	}
-	k := pk.Bytes()
+// remove deletes the entry under primary key k, reporting whether it
+// existed.
+func (t Table[V]) remove(tx *Txn, data *treeTxn[V], k []byte) bool {
 	v, ok := data.get(k)
 	if !ok {
-		return nil
+		return false
 	}
 	data.del(k)
+	tx.track(t.ref, k, v, true, nil, false)
 	for _, fn := range t.cb.delfn {
 		fn(tx, v)
 	}
-	return nil
+	return true
 }
 
 func (t Table[V]) Select(tx *Txn) *TableLister[V] {
@@ -180,27 +307,130 @@ func (t Table[V]) Select(tx *Txn) *TableLister[V] {
 
 func (t Table[V]) table() {}
 
+// encode passes encoded rows of the table to fn in primary key order.
+func (t Table[V]) encode(tx *Txn, fn func(b []byte) error) error {
+	data, err := t.data(tx, false)
+	if err != nil {
+		return err
+	}
+	c := data.cursor()
+	ok := c.first()
+	for ok {
+		b, err := t.codec.Marshal(c.val())
+		if err != nil {
+			return err
+		}
+		if err := fn(b); err != nil {
+			return err
+		}
+		ok = c.next()
+	}
+	return nil
+}
+
+func (t Table[V]) marshal(v interface{}) ([]byte, error) {
+	return t.codec.Marshal(v.(V))
+}
+
+func (t Table[V]) decode(b []byte) (interface{}, error) {
+	var v V
+	err := t.codec.Unmarshal(b, &v)
+	return v, err
+}
+
+// load sets the row, which must be a value of V, adding its primary key
+// to keep. Rows are not checked against unique indexes and foreign
+// keys, as they come from a consistent snapshot.
+func (t Table[V]) load(tx *Txn, v interface{}, keep *treeTxn[struct{}]) error {
+	data, err := t.data(tx, true)
+	if err != nil {
+		return err
+	}
+	k := t.fn(v.(V)).Bytes()
+	keep.set(k, struct{}{})
+	t.set(tx, data, k, v.(V))
+	return nil
+}
+
+// prune deletes the rows of the table whose primary keys are not in
+// keep.
+func (t Table[V]) prune(tx *Txn, keep *treeTxn[struct{}]) error {
+	data, err := t.data(tx, true)
+	if err != nil {
+		return err
+	}
+	del := [][]byte{}
+	c := data.cursor()
+	ok := c.first()
+	for ok {
+		if _, found := keep.get(c.key()); !found {
+			del = append(del, c.key())
+		}
+		ok = c.next()
+	}
+	for _, k := range del {
+		t.remove(tx, data, k)
+	}
+	return nil
+}
+
+// has reports whether the table has an entry under primary key k.
+func (t Table[V]) has(tx *Txn, k []byte) bool {
+	data, err := t.data(tx, false)
+	if err != nil {
+		return false
+	}
+	_, ok := data.get(k)
+	return ok
+}
+
+func (t Table[V]) tableRef() interface{} {
+	return t.ref
+}
+
+func (t Table[V]) tableName() string {
+	return t.name
+}
+
 func (t Table[V]) registerTable(db *DB) error {
 	if t.ref == nil {
 		return errors.New("memdb: table is not referenced")
 	}
-	if _, ok := db.tm[t.ref]; ok {
+	root := db.load()
+	if _, ok := root.tm[t.ref]; ok {
 		return errors.New("memdb: table already registered")
 	}
-	root := unsafe.Pointer(makeTree[V]())
 	if t.idxm.n > 255 {
 		return errors.New("memdb: too many indexes")
 	}
+	if err := t.registerRefs(db); err != nil {
+		return err
+	}
 	n := t.idxm.n
 	db.indexm[t.ref] = t.idxm.n
-	db.tm[t.ref] = make(map[uint8]*unsafe.Pointer, n)
-	db.txfn[t.ref] = make(map[uint8]func(unsafe.Pointer) unsafe.Pointer, n)
+	root.tm[t.ref] = make(map[uint8]unsafe.Pointer, n)
+	db.txfn[t.ref] = make(map[uint8]func(p unsafe.Pointer, write bool) unsafe.Pointer, n)
 	db.commitfn[t.ref] = make(map[uint8]func(unsafe.Pointer) unsafe.Pointer, n)
+	// changes are replayed without checks and actions of foreign keys,
+	// as entries may violate unique indexes or reference deleted ones
+	// until all of them are applied
+	db.replayfn[t.ref] = func(tx *Txn, k []byte, c *change) error {
+		data, err := t.data(tx, true)
+		if err != nil {
+			return err
+		}
+		if c.exists {
+			t.set(tx, data, k, c.value.(V))
+		} else {
+			t.remove(tx, data, k)
+		}
+		return nil
+	}
 	// set table root index
-	db.tm[t.ref][0] = &root
-	db.txfn[t.ref][0] = func(p unsafe.Pointer) unsafe.Pointer {
+	root.tm[t.ref][0] = unsafe.Pointer(makeTree[V]())
+	db.txfn[t.ref][0] = func(p unsafe.Pointer, write bool) unsafe.Pointer {
 		idx := (*tree[V])(p)
-		return unsafe.Pointer(idx.txn(true))
+		return unsafe.Pointer(idx.txn(write))
 	}
 	db.commitfn[t.ref][0] = func(txp unsafe.Pointer) unsafe.Pointer {
 		tx := (*treeTxn[V])(txp)
@@ -208,11 +438,10 @@ func (t Table[V]) registerTable(db *DB) error {
 	}
 	// set filter indexes for each combination
 	for i := 1; i <= n; i++ {
-		idx := unsafe.Pointer(makeTree[*tree[struct{}]]())
-		db.tm[t.ref][uint8(i)] = &idx
-		db.txfn[t.ref][uint8(i)] = func(p unsafe.Pointer) unsafe.Pointer {
-			idx := (*tree[*tree[struct{}]])(atomic.LoadPointer(&p))
-			return unsafe.Pointer(idx.txn(true))
+		root.tm[t.ref][uint8(i)] = unsafe.Pointer(makeTree[*tree[struct{}]]())
+		db.txfn[t.ref][uint8(i)] = func(p unsafe.Pointer, write bool) unsafe.Pointer {
+			idx := (*tree[*tree[struct{}]])(p)
+			return unsafe.Pointer(idx.txn(write))
 		}
 		db.commitfn[t.ref][uint8(i)] = func(txp unsafe.Pointer) unsafe.Pointer {
 			tx := (*treeTxn[*tree[struct{}]])(txp)
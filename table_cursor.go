@@ -1,29 +1,253 @@
 package memdb
 
+import "bytes"
+
+type cursorState int
+
+const (
+	beforeFirst cursorState = iota
+	onEntry
+	afterLast
+)
+
+// TableCursor iterates over the entries of a selection one by one
+// without loading them into memory. The cursor can be moved in both
+// directions; Next and Prev follow the order of the selection.
 type TableCursor[V any] struct {
+	interrupter
 	table Table[V]
 	ids   *treeTxn[struct{}]
 	idx   *treeTxn[V]
 	order *treeTxn[*tree[struct{}]]
+	rules []*OrderRule[V]
 	dir   OrderDirection
+	conds []Cond[V]
+
+	state  cursorState
+	rows   *treeCursor[V]
+	keys   *treeCursor[struct{}]
+	groups *treeCursor[*tree[struct{}]]
+	group  *groupCursor[V]
+}
+
+// Err returns the error of the context the cursor is bound to if
+// the iteration was stopped because it is done.
+func (t *TableCursor[V]) Err() error {
+	return t.err
 }
 
+// Seek moves the cursor to the entry with given primary key. For
+// unordered selections, if there is no such entry the cursor is moved
+// to the closest entry that follows it.
 func (t *TableCursor[V]) Seek(id Key) (V, bool) {
-	return *new(V), false
+	k := id.Bytes()
+	ok := false
+	fwd := t.dir == Asc
+	switch {
+	case t.order != nil:
+		v, has := t.idx.get(k)
+		if has {
+			gk := t.rules[0].index.KeyOf(v).Bytes()
+			ok = t.groups.ceil(gk) && bytes.Equal(t.groups.key(), gk)
+		}
+		if ok {
+			t.group = makeGroupCursor(t.idx, t.groups.val(), t.rules[1:], t.dir, &t.interrupter)
+			ok = t.group.seek(k)
+		}
+		if ok && (t.err != nil || !t.visible()) {
+			ok = false
+		}
+		if !ok {
+			t.state = afterLast
+			return *new(V), false
+		}
+		t.state = onEntry
+		return t.val(), true
+	case t.ids != nil:
+		if fwd {
+			ok = t.keys.ceil(k)
+		} else {
+			ok = t.keys.floor(k)
+		}
+	default:
+		if fwd {
+			ok = t.rows.ceil(k)
+		} else {
+			ok = t.rows.floor(k)
+		}
+	}
+	return t.settle(ok, true)
 }
 
+// First moves the cursor to the first entry of the selection.
 func (t *TableCursor[V]) First() (V, bool) {
-	return *new(V), false
+	return t.settle(t.reset(true), true)
 }
 
+// Last moves the cursor to the last entry of the selection.
 func (t *TableCursor[V]) Last() (V, bool) {
-	return *new(V), false
+	return t.settle(t.reset(false), false)
 }
 
+// Next moves the cursor to the next entry of the selection.
 func (t *TableCursor[V]) Next() (V, bool) {
-	return *new(V), false
+	switch t.state {
+	case beforeFirst:
+		return t.First()
+	case afterLast:
+		return *new(V), false
+	}
+	return t.settle(t.step(true), true)
 }
 
+// Prev moves the cursor to the previous entry of the selection.
 func (t *TableCursor[V]) Prev() (V, bool) {
-	return *new(V), false
+	switch t.state {
+	case beforeFirst:
+		return *new(V), false
+	case afterLast:
+		return t.Last()
+	}
+	return t.settle(t.step(false), false)
+}
+
+// settle skips entries which are not part of the selection and
+// updates the cursor state.
+func (t *TableCursor[V]) settle(ok, forward bool) (V, bool) {
+	for ok && !t.interrupted() && !t.visible() {
+		ok = t.step(forward)
+	}
+	if !ok || t.err != nil {
+		if forward {
+			t.state = afterLast
+		} else {
+			t.state = beforeFirst
+		}
+		return *new(V), false
+	}
+	t.state = onEntry
+	return t.val(), true
+}
+
+// reset moves the cursor to the first or the last position of the
+// underlying trees, without checking the visibility of the entry.
+func (t *TableCursor[V]) reset(forward bool) bool {
+	fwd := forward == (t.dir == Asc)
+	switch {
+	case t.order != nil:
+		var ok bool
+		if forward == (t.rules[0].dir == Asc) {
+			ok = t.groups.first()
+		} else {
+			ok = t.groups.last()
+		}
+		return ok && t.enter(forward)
+	case t.ids != nil:
+		if fwd {
+			return t.keys.first()
+		}
+		return t.keys.last()
+	default:
+		if fwd {
+			return t.rows.first()
+		}
+		return t.rows.last()
+	}
+}
+
+// step moves the cursor by one position of the underlying trees,
+// without checking the visibility of the entry.
+func (t *TableCursor[V]) step(forward bool) bool {
+	fwd := forward == (t.dir == Asc)
+	switch {
+	case t.order != nil:
+		var ok bool
+		if forward {
+			ok = t.group.next()
+		} else {
+			ok = t.group.prev()
+		}
+		if ok {
+			return true
+		}
+		if forward == (t.rules[0].dir == Asc) {
+			ok = t.groups.next()
+		} else {
+			ok = t.groups.prev()
+		}
+		return ok && t.enter(forward)
+	case t.ids != nil:
+		if fwd {
+			return t.keys.next()
+		}
+		return t.keys.prev()
+	default:
+		if fwd {
+			return t.rows.next()
+		}
+		return t.rows.prev()
+	}
+}
+
+// enter moves the cursor into the group of primary keys under the
+// current order key, skipping empty groups.
+func (t *TableCursor[V]) enter(forward bool) bool {
+	fwd := forward == (t.rules[0].dir == Asc)
+	for {
+		t.group = makeGroupCursor(t.idx, t.groups.val(), t.rules[1:], t.dir, &t.interrupter)
+		var ok bool
+		if forward {
+			ok = t.group.first()
+		} else {
+			ok = t.group.last()
+		}
+		if ok {
+			return true
+		}
+		if fwd {
+			ok = t.groups.next()
+		} else {
+			ok = t.groups.prev()
+		}
+		if !ok || t.interrupted() {
+			return false
+		}
+	}
+}
+
+func (t *TableCursor[V]) key() []byte {
+	switch {
+	case t.order != nil:
+		return t.group.key()
+	case t.ids != nil:
+		return t.keys.key()
+	default:
+		return t.rows.key()
+	}
+}
+
+func (t *TableCursor[V]) val() V {
+	if t.order == nil && t.ids == nil {
+		return t.rows.val()
+	}
+	v, _ := t.idx.get(t.key())
+	return v
+}
+
+func (t *TableCursor[V]) visible() bool {
+	if t.order != nil && t.ids != nil {
+		if _, ok := t.ids.get(t.key()); !ok {
+			return false
+		}
+	}
+	if len(t.conds) == 0 {
+		return true
+	}
+	v := t.val()
+	for _, cond := range t.conds {
+		if !cond.Matches(v) {
+			return false
+		}
+	}
+	return true
 }
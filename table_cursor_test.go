@@ -0,0 +1,180 @@
+package memdb
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTableCursor(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tests := []struct {
+		name   string
+		lister func(tx *Txn) *TableLister[*testUser]
+		want   []int
+	}{
+		{
+			name: "unordered_unfiltered_asc",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx)
+			},
+			want: []int{1, 2, 3, 4, 5},
+		},
+		{
+			name: "unordered_unfiltered_desc",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Desc()
+			},
+			want: []int{5, 4, 3, 2, 1},
+		},
+		{
+			name: "unordered_filtered",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.status.Is(1))
+			},
+			want: []int{1, 3},
+		},
+		{
+			name: "ordered_unfiltered_asc",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).OrderBy(users.email.Asc())
+			},
+			want: []int{2, 4, 3, 1, 5},
+		},
+		{
+			name: "ordered_filtered_desc",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.status.Is(0)).OrderBy(users.email.Desc())
+			},
+			want: []int{4, 2},
+		},
+		{
+			name: "ordered_groups",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).OrderBy(users.name.Asc())
+			},
+			want: []int{2, 5, 4, 3, 1},
+		},
+		{
+			name: "ordered_groups_desc_primary_key",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).OrderBy(users.name.Asc()).Desc()
+			},
+			want: []int{5, 2, 4, 3, 1},
+		},
+		{
+			name: "ordered_multiple_rules",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).OrderBy(users.name.Asc(), users.email.Desc())
+			},
+			want: []int{5, 2, 4, 3, 1},
+		},
+		{
+			name: "ordered_multiple_rules_desc_first",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).OrderBy(users.status.Desc(), users.name.Asc())
+			},
+			want: []int{5, 3, 1, 2, 4},
+		},
+		{
+			name: "ordered_multiple_rules_filtered",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).
+					Where(users.status.IsLessThan(2)).
+					OrderBy(users.status.Desc(), users.name.Desc())
+			},
+			want: []int{1, 3, 4, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := db.ReadTx()
+			all, err := tt.lister(tx).All()
+			if err != nil {
+				t.Fatalf("TableLister.All() error = %v", err)
+			}
+			if got := testUserIDs(all); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("TableLister.All() = %v, want %v", got, tt.want)
+			}
+			c, err := tt.lister(tx).Cursor()
+			if err != nil {
+				t.Fatalf("TableLister.Cursor() error = %v", err)
+			}
+			got := []int{}
+			for usr, ok := c.First(); ok; usr, ok = c.Next() {
+				got = append(got, usr.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TableCursor.Next() = %v, want %v", got, tt.want)
+			}
+			got = []int{}
+			for usr, ok := c.Prev(); ok; usr, ok = c.Prev() {
+				got = append([]int{usr.ID}, got...)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TableCursor.Prev() = %v, want %v", got, tt.want)
+			}
+			last := tt.want[len(tt.want)-1]
+			if usr, ok := c.Seek(IntKey(last)); !ok || usr.ID != last {
+				t.Errorf("TableCursor.Seek() = %v, %v, want %v", usr, ok, last)
+			}
+			if _, ok := c.Next(); ok {
+				t.Errorf("TableCursor.Next() after last entry = true, want false")
+			}
+		})
+	}
+}
+
+func TestTableCursor_largeGroup(t *testing.T) {
+	users := make([]*testUser, 200)
+	for i := range users {
+		users[i] = &testUser{ID: i + 1, Status: i % 2, Name: fmt.Sprintf("user-%03d", (i*37)%50)}
+	}
+	db, table := makeTestUserDB(t, users...)
+	tx := db.ReadTx()
+	lister := func() *TableLister[*testUser] {
+		return table.Select(tx).OrderBy(table.status.Asc(), table.name.Desc())
+	}
+	all, err := lister().All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int{0, 3, 99, 100, 195} {
+		page, err := lister().Page(10, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := offset + 10
+		if end > len(all) {
+			end = len(all)
+		}
+		if got, want := testUserIDs(page), testUserIDs(all[offset:end]); !reflect.DeepEqual(got, want) {
+			t.Errorf("TableLister.Page(10, %d) = %v, want %v", offset, got, want)
+		}
+	}
+	// moving back and forth within a partially sorted group
+	c, err := lister().Cursor()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.First()
+	for i := 1; i < 5; i++ {
+		c.Next()
+	}
+	if usr, ok := c.Prev(); !ok || usr.ID != all[3].ID {
+		t.Errorf("TableCursor.Prev() = %v, %v, want %v", usr, ok, all[3].ID)
+	}
+	if usr, ok := c.Seek(IntKey(all[60].ID)); !ok || usr.ID != all[60].ID {
+		t.Errorf("TableCursor.Seek() = %v, %v, want %v", usr, ok, all[60].ID)
+	}
+	if usr, ok := c.Next(); !ok || usr.ID != all[61].ID {
+		t.Errorf("TableCursor.Next() = %v, %v, want %v", usr, ok, all[61].ID)
+	}
+	got := []int{}
+	for usr, ok := c.Last(); ok; usr, ok = c.Prev() {
+		got = append([]int{usr.ID}, got...)
+	}
+	if want := testUserIDs(all); !reflect.DeepEqual(got, want) {
+		t.Errorf("TableCursor.Prev() = %v, want %v", got, want)
+	}
+}
@@ -1,12 +1,26 @@
 package memdb
 
 type TableSelection[V any] struct {
+	interrupter
 	table Table[V]
 	tx    *Txn
 	ids   *treeTxn[struct{}]
 	idx   *treeTxn[V]
 	order *treeTxn[*tree[struct{}]]
+	rules []*OrderRule[V]
 	dir   OrderDirection
+	conds []Cond[V]
+}
+
+// matches reports whether v satisfies the conditions
+// which could not be resolved using indexes.
+func (t *TableSelection[V]) matches(v V) bool {
+	for _, cond := range t.conds {
+		if !cond.Matches(v) {
+			return false
+		}
+	}
+	return true
 }
 
 func (t *TableSelection[V]) pageUnorderedUnfilteredASC(limit, offset int) []V {
@@ -14,14 +28,16 @@ func (t *TableSelection[V]) pageUnorderedUnfilteredASC(limit, offset int) []V {
 	at := 0
 	c := t.idx.cursor()
 	ok := c.first()
-	for ok {
-		if at >= offset {
-			out = append(out, c.val())
+	for ok && !t.interrupted() {
+		if v := c.val(); t.matches(v) {
+			if at >= offset {
+				out = append(out, v)
+			}
+			at++
 		}
 		if limit > 0 && len(out) >= limit {
 			break
 		}
-		at++
 		ok = c.next()
 	}
 	return out
@@ -32,14 +48,16 @@ func (t *TableSelection[V]) pageUnorderedUnfilteredDESC(limit, offset int) []V {
 	at := 0
 	c := t.idx.cursor()
 	ok := c.last()
-	for ok {
-		if at >= offset {
-			out = append(out, c.val())
+	for ok && !t.interrupted() {
+		if v := c.val(); t.matches(v) {
+			if at >= offset {
+				out = append(out, v)
+			}
+			at++
 		}
 		if limit > 0 && len(out) >= limit {
 			break
 		}
-		at++
 		ok = c.prev()
 	}
 	return out
@@ -50,18 +68,19 @@ func (t *TableSelection[V]) pageOrderedUnfilteredASC(limit, offset int) []V {
 	at := 0
 	c := t.order.cursor()
 	ok := c.first()
-	for ok {
-		cc := c.val().txn(false).cursor()
+	for ok && !t.interrupted() {
+		cc := makeGroupCursor(t.idx, c.val(), t.rules[1:], t.dir, &t.interrupter)
 		okk := cc.first()
-		for okk {
-			if at >= offset {
-				v, _ := t.idx.get(cc.key())
-				out = append(out, v)
+		for okk && !t.interrupted() {
+			if v, _ := t.idx.get(cc.key()); t.matches(v) {
+				if at >= offset {
+					out = append(out, v)
+				}
+				at++
 			}
 			if limit > 0 && len(out) >= limit {
 				break
 			}
-			at++
 			okk = cc.next()
 		}
 		if limit > 0 && len(out) >= limit {
@@ -77,18 +96,19 @@ func (t *TableSelection[V]) pageOrderedUnfilteredDESC(limit, offset int) []V {
 	at := 0
 	c := t.order.cursor()
 	ok := c.last()
-	for ok {
-		cc := c.val().txn(false).cursor()
+	for ok && !t.interrupted() {
+		cc := makeGroupCursor(t.idx, c.val(), t.rules[1:], t.dir, &t.interrupter)
 		okk := cc.first()
-		for okk {
-			if at >= offset {
-				v, _ := t.idx.get(cc.key())
-				out = append(out, v)
+		for okk && !t.interrupted() {
+			if v, _ := t.idx.get(cc.key()); t.matches(v) {
+				if at >= offset {
+					out = append(out, v)
+				}
+				at++
 			}
 			if limit > 0 && len(out) >= limit {
 				break
 			}
-			at++
 			okk = cc.next()
 		}
 		if limit > 0 && len(out) >= limit {
@@ -104,18 +124,17 @@ func (t *TableSelection[V]) pageUnorderedFilteredASC(limit, offset int) []V {
 	at := 0
 	c := t.ids.cursor()
 	ok := c.first()
-	for ok {
-		if at >= offset {
-			v, ok := t.idx.get(c.key())
-			if ok {
+	for ok && !t.interrupted() {
+		if v, has := t.idx.get(c.key()); has && t.matches(v) {
+			if at >= offset {
 				out = append(out, v)
 			}
+			at++
 		}
 		if limit > 0 && len(out) >= limit {
 			break
 		}
 		ok = c.next()
-		at++
 	}
 	return out
 }
@@ -125,18 +144,17 @@ func (t *TableSelection[V]) pageUnorderedFilteredDESC(limit, offset int) []V {
 	at := 0
 	c := t.ids.cursor()
 	ok := c.last()
-	for ok {
-		if at >= offset {
-			v, ok := t.idx.get(c.key())
-			if ok {
+	for ok && !t.interrupted() {
+		if v, has := t.idx.get(c.key()); has && t.matches(v) {
+			if at >= offset {
 				out = append(out, v)
 			}
+			at++
 		}
 		if limit > 0 && len(out) >= limit {
 			break
 		}
 		ok = c.prev()
-		at++
 	}
 	return out
 }
@@ -146,21 +164,21 @@ func (t *TableSelection[V]) pageOrderedFilteredASC(limit, offset int) []V {
 	at := 0
 	c := t.order.cursor()
 	ok := c.first()
-	for ok {
-		cc := c.val().txn(false).cursor()
+	for ok && !t.interrupted() {
+		cc := makeGroupCursor(t.idx, c.val(), t.rules[1:], t.dir, &t.interrupter)
 		okk := cc.first()
-		for okk {
-			if at >= offset {
-				_, has := t.ids.get(cc.key())
-				if has {
-					v, _ := t.idx.get(cc.key())
-					out = append(out, v)
+		for okk && !t.interrupted() {
+			if _, has := t.ids.get(cc.key()); has {
+				if v, _ := t.idx.get(cc.key()); t.matches(v) {
+					if at >= offset {
+						out = append(out, v)
+					}
+					at++
 				}
 			}
 			if limit > 0 && len(out) >= limit {
 				break
 			}
-			at++
 			okk = cc.next()
 		}
 		if limit > 0 && len(out) >= limit {
@@ -176,21 +194,21 @@ func (t *TableSelection[V]) pageOrderedFilteredDESC(limit, offset int) []V {
 	at := 0
 	c := t.order.cursor()
 	ok := c.last()
-	for ok {
-		cc := c.val().txn(false).cursor()
+	for ok && !t.interrupted() {
+		cc := makeGroupCursor(t.idx, c.val(), t.rules[1:], t.dir, &t.interrupter)
 		okk := cc.first()
-		for okk {
-			if at >= offset {
-				_, has := t.ids.get(cc.key())
-				if has {
-					v, _ := t.idx.get(cc.key())
-					out = append(out, v)
+		for okk && !t.interrupted() {
+			if _, has := t.ids.get(cc.key()); has {
+				if v, _ := t.idx.get(cc.key()); t.matches(v) {
+					if at >= offset {
+						out = append(out, v)
+					}
+					at++
 				}
 			}
 			if limit > 0 && len(out) >= limit {
 				break
 			}
-			at++
 			okk = cc.next()
 		}
 		if limit > 0 && len(out) >= limit {
@@ -206,15 +224,21 @@ func (t *TableSelection[V]) count() int {
 	if t.ids == nil {
 		c := t.idx.cursor()
 		ok := c.first()
-		for ok {
-			res++
+		for ok && !t.interrupted() {
+			if len(t.conds) == 0 || t.matches(c.val()) {
+				res++
+			}
 			ok = c.next()
 		}
 	} else {
 		c := t.ids.cursor()
 		ok := c.first()
-		for ok {
-			res++
+		for ok && !t.interrupted() {
+			if len(t.conds) == 0 {
+				res++
+			} else if v, has := t.idx.get(c.key()); has && t.matches(v) {
+				res++
+			}
 			ok = c.next()
 		}
 	}
@@ -225,6 +249,9 @@ func (t *TableSelection[V]) page(limit, offset int) []V {
 	ordered := t.order != nil
 	filtered := t.ids != nil
 	asc := t.dir == Asc
+	if ordered {
+		asc = t.rules[0].dir == Asc
+	}
 	switch {
 	case !ordered && !filtered && asc:
 		return t.pageUnorderedUnfilteredASC(limit, offset)
@@ -248,8 +275,33 @@ func (t *TableSelection[V]) page(limit, offset int) []V {
 
 func (t *TableSelection[V]) one() (V, error) {
 	data := t.page(1, 0)
+	if t.err != nil {
+		return *new(V), t.err
+	}
 	if len(data) == 0 {
 		return *new(V), ErrNotFound
 	}
 	return data[0], nil
 }
+
+func (t *TableSelection[V]) cursor() *TableCursor[V] {
+	c := &TableCursor[V]{
+		interrupter: interrupter{ctx: t.ctx},
+		table:       t.table,
+		ids:         t.ids,
+		idx:         t.idx,
+		order:       t.order,
+		rules:       t.rules,
+		dir:         t.dir,
+		conds:       t.conds,
+	}
+	switch {
+	case t.order != nil:
+		c.groups = t.order.cursor()
+	case t.ids != nil:
+		c.keys = t.ids.cursor()
+	default:
+		c.rows = t.idx.cursor()
+	}
+	return c
+}
@@ -2,18 +2,30 @@ package memdb
 
 import (
 	"bytes"
+	"context"
 )
 
 type TableLister[V any] struct {
 	table Table[V]
 	tx    *Txn
+	ctx   context.Context
 	conds []Cond[V]
-	order Index[V]
+	order []*OrderRule[V]
 	dir   OrderDirection
 }
 
-func (t *TableLister[V]) OrderBy(order Index[V]) *TableLister[V] {
-	t.order = order
+// OrderBy sorts entries by the first rule, breaking ties with each
+// following rule. Entries which are equal in all the rules are sorted
+// by primary key in the direction set with Asc or Desc.
+//
+// Only the first rule is served by its index. With more rules, all the
+// entries sharing a key of the first rule are read, and their keys of
+// the following rules computed, before the first of them is returned.
+// This takes time linear in the number of such entries, even if only a
+// page of them is returned. If the first rule has few distinct keys,
+// order by a single index created with IndexMultiple instead.
+func (t *TableLister[V]) OrderBy(rules ...*OrderRule[V]) *TableLister[V] {
+	t.order = append(t.order, rules...)
 	return t
 }
 
@@ -32,30 +44,85 @@ func (t *TableLister[V]) Where(conds ...Cond[V]) *TableLister[V] {
 	return t
 }
 
+// WithContext binds the query to ctx instead of the context of its
+// transaction. Once ctx is done, the query stops and returns its error.
+func (t *TableLister[V]) WithContext(ctx context.Context) *TableLister[V] {
+	t.ctx = ctx
+	return t
+}
+
 func (t *TableLister[V]) Count() (int, error) {
-	return t.selector().count(), nil
+	if err := t.check(); err != nil {
+		return 0, err
+	}
+	selector := t.selector()
+	n := selector.count()
+	if selector.err != nil {
+		return 0, selector.err
+	}
+	return n, nil
 }
 
 func (t *TableLister[V]) Page(limit, offset int) ([]V, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
 	selector := t.selector()
-	return selector.page(limit, offset), nil
+	out := selector.page(limit, offset)
+	if selector.err != nil {
+		return nil, selector.err
+	}
+	return out, nil
 }
 
 func (t *TableLister[V]) All() ([]V, error) {
-	selector := t.selector()
-	return selector.page(0, 0), nil
+	return t.Page(0, 0)
 }
 
 func (t *TableLister[V]) One() (V, error) {
+	if err := t.check(); err != nil {
+		return *new(V), err
+	}
 	selector := t.selector()
 	return selector.one()
 }
 
 func (t *TableLister[V]) Cursor() (*TableCursor[V], error) {
-	return nil, nil
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+	selector := t.selector()
+	if selector.err != nil {
+		return nil, selector.err
+	}
+	return selector.cursor(), nil
+}
+
+func (t *TableLister[V]) check() error {
+	if err := t.tx.check(false); err != nil {
+		return err
+	}
+	if t.ctx != nil {
+		return t.ctx.Err()
+	}
+	return nil
+}
+
+func (t *TableLister[V]) context() context.Context {
+	if t.ctx != nil {
+		return t.ctx
+	}
+	return t.tx.ctx
 }
 
 func (t *TableLister[V]) selector() *TableSelection[V] {
+	sel := &TableSelection[V]{
+		interrupter: interrupter{ctx: t.context()},
+		table:       t.table,
+		tx:          t.tx,
+		rules:       t.order,
+		dir:         t.dir,
+	}
 	indexed := []indexCond[V]{}
 	basic := []Cond[V]{}
 	for _, cond := range t.conds {
@@ -65,6 +132,15 @@ func (t *TableLister[V]) selector() *TableSelection[V] {
 			basic = append(basic, cond)
 		}
 	}
+	// serializable transactions record the index ranges they scan
+	t.tx.readWhere(t.table.ref, func(v interface{}) bool {
+		for _, cnd := range indexed {
+			if !cnd.Matches(v.(V)) {
+				return false
+			}
+		}
+		return true
+	})
 	var ids *treeTxn[struct{}]
 	if len(indexed) > 0 {
 		idTree := makeTree[struct{}]()
@@ -83,38 +159,36 @@ func (t *TableLister[V]) selector() *TableSelection[V] {
 			case *LessThanCond[V]:
 				c := idx.cursor()
 				k := cnd.key.Bytes()
-				ok := c.seek(k)
-				for ok && bytes.Equal(c.key(), k) { // skip maching
+				ok := c.floor(k)
+				if ok && bytes.Equal(c.key(), k) { // skip matching
 					ok = c.prev()
 				}
-				for ok {
+				for ok && !sel.interrupted() {
 					tmp = tmp.union(c.val())
 					ok = c.prev()
 				}
 			case *LessThanOrEqualCond[V]:
 				c := idx.cursor()
-				k := cnd.key.Bytes()
-				ok := c.seek(k)
-				for ok {
+				ok := c.floor(cnd.key.Bytes())
+				for ok && !sel.interrupted() {
 					tmp = tmp.union(c.val())
 					ok = c.prev()
 				}
 			case *GreaterThanCond[V]:
 				c := idx.cursor()
 				k := cnd.key.Bytes()
-				ok := c.seek(k)
-				for ok && bytes.Equal(c.key(), k) { // skip maching
+				ok := c.ceil(k)
+				if ok && bytes.Equal(c.key(), k) { // skip matching
 					ok = c.next()
 				}
-				for ok {
+				for ok && !sel.interrupted() {
 					tmp = tmp.union(c.val())
 					ok = c.next()
 				}
 			case *GreaterThanOrEqualCond[V]:
 				c := idx.cursor()
-				k := cnd.key.Bytes()
-				ok := c.seek(k)
-				for ok {
+				ok := c.ceil(cnd.key.Bytes())
+				for ok && !sel.interrupted() {
 					tmp = tmp.union(c.val())
 					ok = c.next()
 				}
@@ -128,9 +202,12 @@ func (t *TableLister[V]) selector() *TableSelection[V] {
 		ids = idTree.txn(false)
 	}
 	var order *treeTxn[*tree[struct{}]]
-	if t.order != nil {
-		order = (*treeTxn[*tree[struct{}]])(t.tx.tm[t.table.ref][uint8(t.table.idxm.m[t.order]+1)])
+	if len(t.order) > 0 {
+		order = (*treeTxn[*tree[struct{}]])(t.tx.tm[t.table.ref][uint8(t.table.idxm.m[t.order[0].index]+1)])
 	}
-	selection := (*treeTxn[V])(t.tx.tm[t.table.ref][0])
-	return &TableSelection[V]{table: t.table, tx: t.tx, idx: selection, ids: ids, order: order, dir: t.dir}
+	sel.idx = (*treeTxn[V])(t.tx.tm[t.table.ref][0])
+	sel.ids = ids
+	sel.order = order
+	sel.conds = basic
+	return sel
 }
@@ -0,0 +1,104 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTableLister_Where(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	notAnna := CondFunc[*testUser](func(usr *testUser) bool {
+		return usr.Name != "Anna"
+	})
+	tests := []struct {
+		name   string
+		lister func(tx *Txn) *TableLister[*testUser]
+		limit  int
+		offset int
+		want   []int
+	}{
+		{
+			name: "func",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(notAnna)
+			},
+			want: []int{1, 3, 4},
+		},
+		{
+			name: "func_page",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(notAnna)
+			},
+			limit:  1,
+			offset: 1,
+			want:   []int{3},
+		},
+		{
+			name: "func_and_index",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.status.Is(0), notAnna)
+			},
+			want: []int{4},
+		},
+		{
+			name: "func_ordered_desc_page",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(notAnna).OrderBy(users.email.Desc())
+			},
+			limit:  2,
+			offset: 1,
+			want:   []int{3, 4},
+		},
+		{
+			name: "less_than",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.email.LessThan("c@example.com"))
+			},
+			want: []int{2, 4},
+		},
+		{
+			name: "less_than_missing_key",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.email.LessThan("bb"))
+			},
+			want: []int{2, 4},
+		},
+		{
+			name: "greater_than_or_equal_missing_key",
+			lister: func(tx *Txn) *TableLister[*testUser] {
+				return users.Select(tx).Where(users.email.GreaterThanOrEqual("bb"))
+			},
+			want: []int{1, 3, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := db.ReadTx()
+			list, err := tt.lister(tx).Page(tt.limit, tt.offset)
+			if err != nil {
+				t.Fatalf("TableLister.Page() error = %v", err)
+			}
+			if got := testUserIDs(list); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TableLister.Page() = %v, want %v", got, tt.want)
+			}
+			if tt.limit > 0 || tt.offset > 0 {
+				return
+			}
+			n, err := tt.lister(tx).Count()
+			if err != nil {
+				t.Fatalf("TableLister.Count() error = %v", err)
+			}
+			if n != len(tt.want) {
+				t.Errorf("TableLister.Count() = %v, want %v", n, len(tt.want))
+			}
+			c, _ := tt.lister(tx).Cursor()
+			got := []int{}
+			for usr, ok := c.First(); ok; usr, ok = c.Next() {
+				got = append(got, usr.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TableCursor.Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package memdb
+
+import (
+	"context"
+)
+
+// Watch sends the result of All to the returned channel, and then
+// sends it again each time a commit changes entries which may affect
+// it. See WatchPage for details.
+func (t *TableLister[V]) Watch(ctx context.Context) (<-chan []V, error) {
+	return t.WatchPage(ctx, 0, 0)
+}
+
+// WatchPage sends the result of Page to the returned channel, and then
+// sends it again each time a commit inserts, updates or deletes an
+// entry matching conditions of the query, before or after the change.
+// The query is always evaluated on committed data. If the receiver
+// does not keep up, only the most recent result is kept in the
+// channel. The channel is closed when ctx is done, or when the query
+// fails, e.g. because its own context is done.
+func (t *TableLister[V]) WatchPage(ctx context.Context, limit, offset int) (<-chan []V, error) {
+	if err := t.tx.check(false); err != nil {
+		return nil, err
+	}
+	// copy the query, so it is not affected by later modifications,
+	// without the transaction, so the goroutine does not keep its root
+	// and all the commits following it reachable
+	db := t.tx.db
+	q := *t
+	q.tx = nil
+	q.conds = append([]Cond[V]{}, t.conds...)
+	q.order = append([]*OrderRule[V]{}, t.order...)
+	t = &q
+	sub := db.WatchWithOptions(WatchOptions{Buffer: 64, Policy: WatchDrop}, t.table)
+	out := make(chan []V, 1)
+	version, res, err := t.eval(db, limit, offset)
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+	out <- res
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		dropped := sub.Dropped()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case cs := <-sub.C():
+				if cs.Version <= version {
+					continue
+				}
+				// changes discarded by the subscription could
+				// have affected the result as well
+				n := sub.Dropped()
+				if n == dropped && !t.affected(cs.Changes(t.table)) {
+					continue
+				}
+				dropped = n
+				version, res, err = t.eval(db, limit, offset)
+				if err != nil {
+					return
+				}
+				select {
+				case out <- res:
+				default:
+					// replace the result not received yet
+					select {
+					case <-out:
+					default:
+					}
+					out <- res
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+// eval runs the query on the latest committed data.
+func (t *TableLister[V]) eval(db *DB, limit, offset int) (uint64, []V, error) {
+	q := *t
+	q.tx = db.ReadTx()
+	defer q.tx.Abort()
+	res, err := q.Page(limit, offset)
+	return q.tx.Version(), res, err
+}
+
+// affected reports whether any of the changes may affect the result.
+func (t *TableLister[V]) affected(changes []Change) bool {
+	for _, c := range changes {
+		if c.Kind != ChangeInsert && t.matches(c.Prev.(V)) {
+			return true
+		}
+		if c.Kind != ChangeDelete && t.matches(c.Value.(V)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *TableLister[V]) matches(v V) bool {
+	for _, cond := range t.conds {
+		if !cond.Matches(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package memdb
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTableLister_Watch(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch, err := users.Select(db.ReadTx()).Where(users.status.Is(1)).Watch(ctx)
+	if err != nil {
+		t.Fatalf("TableLister.Watch() error = %v", err)
+	}
+	expect := func(want []int) {
+		t.Helper()
+		select {
+		case res := <-ch:
+			if got := testUserIDs(res); !reflect.DeepEqual(got, want) {
+				t.Errorf("TableLister.Watch() = %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("TableLister.Watch() did not send result %v", want)
+		}
+	}
+	commit := func(usr *testUser) {
+		tx := db.WriteTx()
+		if err := users.Set(tx, usr); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect([]int{1, 3})
+
+	commit(&testUser{ID: 2, Status: 0, Name: "Anna Smith"})
+	select {
+	case res := <-ch:
+		t.Errorf("TableLister.Watch() sent %v for unrelated change", testUserIDs(res))
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	commit(&testUser{ID: 4, Status: 1})
+	expect([]int{1, 3, 4})
+	commit(&testUser{ID: 1, Status: 0})
+	expect([]int{3, 4})
+
+	cancel()
+	for range ch {
+	}
+}
+
+func TestTableLister_Watch_error(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	qctx, qcancel := context.WithCancel(context.Background())
+	ch, err := users.Select(db.ReadTx()).WithContext(qctx).Watch(context.Background())
+	if err != nil {
+		t.Fatalf("TableLister.Watch() error = %v", err)
+	}
+	<-ch
+	// the query fails once its context is done, which stops the watch
+	qcancel()
+	tx := db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 6})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case res, ok := <-ch:
+		if ok {
+			t.Errorf("TableLister.Watch() sent %v after the query failed", testUserIDs(res))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("TableLister.Watch() channel is not closed after the query failed")
+	}
+	if _, err := users.Select(db.ReadTx()).WithContext(qctx).Watch(context.Background()); err != context.Canceled {
+		t.Errorf("TableLister.Watch() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestTableLister_Watch_releasesTx(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tx := db.ReadTx()
+	released := watchRelease(tx.root)
+	if _, err := users.Select(tx).Watch(ctx); err != nil {
+		t.Fatalf("TableLister.Watch() error = %v", err)
+	}
+	tx = nil
+	for i := 0; i < 3; i++ {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 10 + i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectReleased(t, released, "TableLister.Watch() keeps the root of the transaction reachable")
+}
@@ -0,0 +1,85 @@
+package memdb
+
+import (
+	"testing"
+)
+
+type testUser struct {
+	ID     int
+	Status int
+	Email  string
+	Name   string
+}
+
+type testUserTable struct {
+	Table[*testUser]
+	status *IntIndex[*testUser]
+	email  *StringIndex[*testUser]
+	name   *StringIndex[*testUser]
+}
+
+func makeTestUserTable() testUserTable {
+	table := NewTable(func(usr *testUser) Key {
+		return IntKey(usr.ID)
+	})
+	table, status := table.IndexInt(func(usr *testUser) int {
+		return usr.Status
+	})
+	table, email := table.IndexString(func(usr *testUser) string {
+		return usr.Email
+	})
+	table, name := table.IndexString(func(usr *testUser) string {
+		return usr.Name
+	})
+	return testUserTable{
+		Table:  table,
+		status: status,
+		email:  email,
+		name:   name,
+	}
+}
+
+func makeTestUserDB(t *testing.T, users ...*testUser) (*DB, testUserTable) {
+	t.Helper()
+	table := makeTestUserTable()
+	db, err := Init(table)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	tx := db.WriteTx()
+	if err := table.SetMulti(tx, users); err != nil {
+		t.Fatalf("Table.SetMulti() error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Txn.Commit() error = %v", err)
+	}
+	return db, table
+}
+
+func openTestUserDB(t *testing.T, opts Options) (*DB, testUserTable) {
+	t.Helper()
+	table := makeTestUserTable()
+	db, err := InitWithOptions(opts, table)
+	if err != nil {
+		t.Fatalf("InitWithOptions() error = %v", err)
+	}
+	return db, table
+}
+
+func testUserIDs(users []*testUser) []int {
+	ids := []int{}
+	for _, usr := range users {
+		ids = append(ids, usr.ID)
+	}
+	return ids
+}
+
+func makeTestUsers() []*testUser {
+	return []*testUser{
+		{ID: 1, Status: 1, Email: "d@example.com", Name: "Dave"},
+		{ID: 2, Status: 0, Email: "a@example.com", Name: "Anna"},
+		{ID: 3, Status: 1, Email: "c@example.com", Name: "Carl"},
+		{ID: 4, Status: 0, Email: "b@example.com", Name: "Bob"},
+		{ID: 5, Status: 2, Email: "e@example.com", Name: "Anna"},
+	}
+}
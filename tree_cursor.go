@@ -36,6 +36,16 @@ func (c *treeCursor[V]) seek(k []byte) bool {
 	return c.node != nil
 }
 
+func (c *treeCursor[V]) ceil(k []byte) bool {
+	c.node = c.txn.root.ceil(k)
+	return c.node != nil
+}
+
+func (c *treeCursor[V]) floor(k []byte) bool {
+	c.node = c.txn.root.floor(k)
+	return c.node != nil
+}
+
 func (c *treeCursor[V]) first() bool {
 	c.node = c.txn.root.min()
 	return c.node != nil
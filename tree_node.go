@@ -240,6 +240,42 @@ func (n *node[V]) seekNode(k []byte) *node[V] {
 	}
 }
 
+// ceil returns the node with the smallest key greater than or equal to k.
+func (n *node[V]) ceil(k []byte) *node[V] {
+	if n == nil {
+		return nil
+	}
+	cmp := bytes.Compare(k, n.k)
+	if cmp == 0 {
+		return n
+	} else if cmp > 0 {
+		return n.right.ceil(k)
+	}
+	c := n.left.ceil(k)
+	if c == nil {
+		return n
+	}
+	return c
+}
+
+// floor returns the node with the largest key less than or equal to k.
+func (n *node[V]) floor(k []byte) *node[V] {
+	if n == nil {
+		return nil
+	}
+	cmp := bytes.Compare(k, n.k)
+	if cmp == 0 {
+		return n
+	} else if cmp < 0 {
+		return n.left.floor(k)
+	}
+	f := n.right.floor(k)
+	if f == nil {
+		return n
+	}
+	return f
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -215,3 +215,34 @@ func Test_node_rotateRight(t *testing.T) {
 		})
 	}
 }
+
+func Test_node_ceil_floor(t *testing.T) {
+	root := makeTestTree[int]().add("b", 1).add("d", 2).add("f", 3).finalize().root
+	tests := []struct {
+		name  string
+		key   string
+		ceil  string
+		floor string
+	}{
+		{name: "before_first", key: "a", ceil: "b", floor: ""},
+		{name: "exact", key: "d", ceil: "d", floor: "d"},
+		{name: "between", key: "c", ceil: "d", floor: "b"},
+		{name: "after_last", key: "g", ceil: "", floor: "f"},
+	}
+	key := func(n *node[int]) string {
+		if n == nil {
+			return ""
+		}
+		return string(n.k)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := key(root.ceil([]byte(tt.key))); got != tt.ceil {
+				t.Errorf("node.ceil() = %q, want %q", got, tt.ceil)
+			}
+			if got := key(root.floor([]byte(tt.key))); got != tt.floor {
+				t.Errorf("node.floor() = %q, want %q", got, tt.floor)
+			}
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package memdb
+
+// OnCommit registers fn to be called after the transaction is
+// successfully committed.
+func (tx *Txn) OnCommit(fn func()) {
+	tx.AfterCommit(func(uint64) {
+		fn()
+	})
+}
+
+// AfterCommit registers fn to be called after the transaction is
+// successfully committed, with the version of the database which
+// includes its changes.
+func (tx *Txn) AfterCommit(fn func(version uint64)) {
+	if tx.tm == nil {
+		return
+	}
+	tx.onCommit = append(tx.onCommit, fn)
+}
+
+// OnAbort registers fn to be called after the transaction is aborted,
+// including when its commit fails.
+func (tx *Txn) OnAbort(fn func()) {
+	if tx.tm == nil {
+		return
+	}
+	tx.onAbort = append(tx.onAbort, fn)
+}
+
+func (tx *Txn) runAbortHooks() {
+	for _, fn := range tx.onAbort {
+		fn()
+	}
+}
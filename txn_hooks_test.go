@@ -0,0 +1,58 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTxn_hooks(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	calls := []string{}
+	register := func(tx *Txn, name string) {
+		tx.OnCommit(func() {
+			calls = append(calls, name+":commit")
+		})
+		tx.OnAbort(func() {
+			calls = append(calls, name+":abort")
+		})
+	}
+
+	tx := db.WriteTx()
+	register(tx, "a")
+	_ = users.Set(tx, &testUser{ID: 6})
+	var version uint64
+	tx.AfterCommit(func(v uint64) {
+		version = v
+		calls = append(calls, "a:after")
+	})
+	sp := tx.Savepoint()
+	register(tx, "discarded")
+	_ = tx.RollbackTo(sp)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if version != db.load().version || version == 0 {
+		t.Errorf("Txn.AfterCommit() version = %v, want %v", version, db.load().version)
+	}
+
+	tx = db.WriteTx()
+	register(tx, "b")
+	tx.Abort()
+	tx.Abort()
+
+	tx1, tx2 := db.WriteTx(), db.WriteTx()
+	register(tx2, "c")
+	_ = users.Del(tx1, IntKey(1))
+	_ = users.Del(tx2, IntKey(1))
+	if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != ErrConflict {
+		t.Fatalf("Txn.Commit() error = %v, want %v", err, ErrConflict)
+	}
+
+	want := []string{"a:commit", "a:after", "b:abort", "c:abort"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("hook calls = %v, want %v", calls, want)
+	}
+}
@@ -0,0 +1,123 @@
+package memdb
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTxn_lifecycle(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	done := func(tx *Txn) {
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	aborted := func(tx *Txn) {
+		tx.Abort()
+	}
+	tests := []struct {
+		name   string
+		write  bool
+		finish func(tx *Txn)
+		read   error
+		modify error
+	}{
+		{name: "read", write: false, read: nil, modify: ErrTxnReadOnly},
+		{name: "write", write: true, read: nil, modify: nil},
+		{name: "read_committed", write: false, finish: done, read: ErrTxnDone, modify: ErrTxnDone},
+		{name: "read_aborted", write: false, finish: aborted, read: ErrTxnDone, modify: ErrTxnDone},
+		{name: "modifycommitted", write: true, finish: done, read: ErrTxnDone, modify: ErrTxnDone},
+		{name: "modifyaborted", write: true, finish: aborted, read: ErrTxnDone, modify: ErrTxnDone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := db.Tx(tt.write)
+			if tt.finish != nil {
+				tt.finish(tx)
+			}
+			if _, err := users.Get(tx, IntKey(1)); err != tt.read {
+				t.Errorf("Table.Get() error = %v, want %v", err, tt.read)
+			}
+			if _, err := users.Select(tx).All(); err != tt.read {
+				t.Errorf("TableLister.All() error = %v, want %v", err, tt.read)
+			}
+			if _, err := users.Select(tx).Count(); err != tt.read {
+				t.Errorf("TableLister.Count() error = %v, want %v", err, tt.read)
+			}
+			if _, err := users.Select(tx).Cursor(); err != tt.read {
+				t.Errorf("TableLister.Cursor() error = %v, want %v", err, tt.read)
+			}
+			if err := users.Set(tx, &testUser{ID: 9}); err != tt.modify {
+				t.Errorf("Table.Set() error = %v, want %v", err, tt.modify)
+			}
+			if err := users.SetMulti(tx, []*testUser{{ID: 9}}); err != tt.modify {
+				t.Errorf("Table.SetMulti() error = %v, want %v", err, tt.modify)
+			}
+			if err := users.Del(tx, IntKey(1)); err != tt.modify {
+				t.Errorf("Table.Del() error = %v, want %v", err, tt.modify)
+			}
+			if err := users.DelMulti(tx, []Key{IntKey(2)}); err != tt.modify {
+				t.Errorf("Table.DelMulti() error = %v, want %v", err, tt.modify)
+			}
+			want := tt.read
+			if err := tx.Commit(); err != want {
+				t.Errorf("Txn.Commit() error = %v, want %v", err, want)
+			}
+			if err := tx.Commit(); err != ErrTxnDone {
+				t.Errorf("Txn.Commit() second call error = %v, want %v", err, ErrTxnDone)
+			}
+			tx.Abort()
+		})
+	}
+	if n, _ := users.Select(db.ReadTx()).Count(); n != 4 {
+		t.Errorf("TableLister.Count() = %v, want 4", n)
+	}
+}
+
+// watchRelease returns a channel which is closed once the root is
+// garbage collected.
+func watchRelease(root *dbRoot) <-chan struct{} {
+	released := make(chan struct{})
+	runtime.SetFinalizer(root, func(*dbRoot) { close(released) })
+	return released
+}
+
+// expectReleased fails the test unless the channel returned by
+// watchRelease is closed within a second.
+func expectReleased(t *testing.T, released <-chan struct{}, msg string) {
+	t.Helper()
+	deadline := time.After(time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-released:
+			return
+		case <-deadline:
+			t.Fatal(msg)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestTxn_releasesRoot(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx := db.WriteTx()
+	released := watchRelease(tx.root)
+	_ = users.Set(tx, &testUser{ID: 10})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		wtx := db.WriteTx()
+		_ = users.Set(wtx, &testUser{ID: 20 + i})
+		if err := wtx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectReleased(t, released, "committed transaction keeps its root reachable")
+	if v := tx.Changes().Version; v != 2 {
+		t.Errorf("Txn.Changes().Version after commit = %v, want 2", v)
+	}
+	runtime.KeepAlive(tx)
+}
@@ -0,0 +1,133 @@
+package memdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// indexRef locates the tree of an index within transactions. It is set
+// when the index is added to a table.
+type indexRef[V any] struct {
+	table  *V
+	pos    uint8
+	unique bool
+}
+
+// Unique reports whether no two entries of the table can share a key
+// in the index.
+func (r *indexRef[V]) Unique() bool {
+	return r.unique
+}
+
+// lookup returns the entry with the lowest primary key among those
+// with key k in the index.
+func lookup[V any](tx *Txn, f Index[V], r *indexRef[V], k Key) (V, error) {
+	if err := tx.check(false); err != nil {
+		return *new(V), err
+	}
+	p, ok := tx.tm[r.table][r.pos]
+	if !ok {
+		return *new(V), errors.New("memdb: table not found in transaction")
+	}
+	kb := k.Bytes()
+	tx.readWhere(r.table, func(v interface{}) bool {
+		return bytes.Equal(f.KeyOf(v.(V)).Bytes(), kb)
+	})
+	ids, ok := (*treeTxn[*tree[struct{}]])(p).get(kb)
+	if !ok {
+		return *new(V), ErrNotFound
+	}
+	c := ids.txn(false).cursor()
+	if !c.first() {
+		return *new(V), ErrNotFound
+	}
+	v, ok := (*treeTxn[V])(tx.tm[r.table][0]).get(c.key())
+	if !ok {
+		return *new(V), ErrNotFound
+	}
+	return v, nil
+}
+
+// UniqueViolationError is returned when an entry would share the key
+// of a unique index with another entry of the table. It matches
+// ErrUniqueViolation with errors.Is.
+type UniqueViolationError struct {
+	// Table is the name of the table.
+	Table string
+	// Index is the violated index, e.g. *StringIndex[V].
+	Index interface{}
+	// Key is the key of the index shared by both entries.
+	Key []byte
+	// Existing is the primary key of the entry already using the key.
+	Existing []byte
+}
+
+func (e *UniqueViolationError) Error() string {
+	return fmt.Sprintf("memdb: key %x of unique index %T of table %s is already used by entry %x",
+		e.Key, e.Index, e.Table, e.Existing)
+}
+
+func (e *UniqueViolationError) Is(err error) bool {
+	return err == ErrUniqueViolation
+}
+
+// registerUnique makes Set fail for entries sharing a key in the index
+// with other entries.
+func (t Table[V]) registerUnique(f Index[V], r *indexRef[V]) Table[V] {
+	t.cb.checkfn = append(t.cb.checkfn, func(tx *Txn, k []byte, v V) error {
+		idx := (*treeTxn[*tree[struct{}]])(tx.tm[t.ref][r.pos])
+		ik := f.KeyOf(v).Bytes()
+		ids, ok := idx.get(ik)
+		if !ok {
+			return nil
+		}
+		c := ids.txn(false).cursor()
+		ok = c.first()
+		for ok {
+			if !bytes.Equal(c.key(), k) {
+				return &UniqueViolationError{
+					Table:    tx.db.names[t.ref],
+					Index:    f,
+					Key:      ik,
+					Existing: c.key(),
+				}
+			}
+			ok = c.next()
+		}
+		return nil
+	})
+	return t
+}
+
+// checkUnique returns an error if any unique index key of v, stored
+// under primary key k, is used by another entry.
+func (t Table[V]) checkUnique(tx *Txn, k []byte, v V) error {
+	for _, fn := range t.cb.checkfn {
+		if err := fn(tx, k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks entries of the table set by the transaction without
+// checks, e.g. when its changes are replayed on top of a concurrent
+// commit.
+func (t Table[V]) validate(tx *Txn) error {
+	changes, ok := tx.changes[t.ref]
+	if !ok || len(t.cb.checkfn) == 0 {
+		return nil
+	}
+	c := changes.cursor()
+	ok = c.first()
+	for ok {
+		if ch := c.val(); ch.exists {
+			if err := t.checkUnique(tx, c.key(), ch.value.(V)); err != nil {
+				return err
+			}
+		}
+		ok = c.next()
+	}
+	return nil
+}
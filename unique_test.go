@@ -0,0 +1,166 @@
+package memdb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+type testAccount struct {
+	ID     int
+	Email  string
+	Org    string
+	Handle string
+}
+
+type testAccountTable struct {
+	Table[*testAccount]
+	email  *StringIndex[*testAccount]
+	handle *CombinedIndex[*testAccount]
+}
+
+func makeTestAccountDB(t *testing.T, accounts ...*testAccount) (*DB, testAccountTable) {
+	t.Helper()
+	table := NewTable(func(a *testAccount) Key {
+		return IntKey(a.ID)
+	})
+	table, email := table.IndexStringUnique(func(a *testAccount) string {
+		return a.Email
+	})
+	table, handle := table.IndexMultipleUnique(func(a *testAccount) CombinedKey {
+		return CombinedKey{StringKey(a.Org), StringKey(a.Handle)}
+	})
+	accts := testAccountTable{Table: table, email: email, handle: handle}
+	db, err := Init(accts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.WriteTx()
+	if err := accts.SetMulti(tx, accounts); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	return db, accts
+}
+
+func TestTable_Set_unique(t *testing.T) {
+	db, accts := makeTestAccountDB(t,
+		&testAccount{ID: 1, Email: "a@example.com", Org: "x", Handle: "a"},
+		&testAccount{ID: 2, Email: "b@example.com", Org: "x", Handle: "b"},
+	)
+	tx := db.WriteTx()
+	defer tx.Abort()
+	err := accts.Set(tx, &testAccount{ID: 3, Email: "a@example.com", Org: "y", Handle: "a"})
+	if !errors.Is(err, ErrUniqueViolation) {
+		t.Fatalf("Table.Set() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	var uerr *UniqueViolationError
+	if !errors.As(err, &uerr) {
+		t.Fatalf("Table.Set() error = %T, want *UniqueViolationError", err)
+	}
+	if uerr.Index != accts.email || string(uerr.Key) != "a@example.com" || !reflect.DeepEqual(uerr.Existing, IntKey(1).Bytes()) {
+		t.Errorf("UniqueViolationError = %+v", uerr)
+	}
+	if want := fmt.Sprintf("memdb: key 61406578616d706c652e636f6d of unique index %T of table %s is already used by entry %x",
+		accts.email, uerr.Table, uerr.Existing); err.Error() != want {
+		t.Errorf("UniqueViolationError.Error() = %q, want %q", err.Error(), want)
+	}
+	if _, err := accts.Get(tx, IntKey(3)); err != ErrNotFound {
+		t.Errorf("Table.Get() after violation error = %v, want %v", err, ErrNotFound)
+	}
+	err = accts.Set(tx, &testAccount{ID: 3, Email: "c@example.com", Org: "x", Handle: "b"})
+	if uerr, ok := err.(*UniqueViolationError); !ok || uerr.Index != accts.handle {
+		t.Errorf("Table.Set() error = %v, want violation of combined index", err)
+	}
+	// entries keep their own keys and can take released ones
+	if err := accts.Set(tx, &testAccount{ID: 1, Email: "a@example.com", Org: "x", Handle: "aa"}); err != nil {
+		t.Errorf("Table.Set() update error = %v", err)
+	}
+	if err := accts.Set(tx, &testAccount{ID: 3, Email: "c@example.com", Org: "x", Handle: "a"}); err != nil {
+		t.Errorf("Table.Set() with released key error = %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTable_SetMulti_unique(t *testing.T) {
+	db, accts := makeTestAccountDB(t, &testAccount{ID: 1, Email: "a@example.com"})
+	tx := db.WriteTx()
+	defer tx.Abort()
+	err := accts.SetMulti(tx, []*testAccount{
+		{ID: 2, Email: "b@example.com", Handle: "b"},
+		{ID: 3, Email: "b@example.com", Handle: "c"},
+	})
+	if !errors.Is(err, ErrUniqueViolation) {
+		t.Fatalf("Table.SetMulti() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	if n := tx.Changes().Len(); n != 0 {
+		t.Errorf("Txn.Changes().Len() after violation = %v, want 0", n)
+	}
+	if _, err := accts.email.Get(tx, "b@example.com"); err != ErrNotFound {
+		t.Errorf("StringIndex.Get() after violation error = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestIndex_Get(t *testing.T) {
+	db, accts := makeTestAccountDB(t,
+		&testAccount{ID: 1, Email: "a@example.com", Org: "x", Handle: "a"},
+		&testAccount{ID: 2, Email: "b@example.com", Org: "y", Handle: "a"},
+	)
+	tx := db.ReadTx()
+	acct, err := accts.email.Get(tx, "b@example.com")
+	if err != nil || acct.ID != 2 {
+		t.Errorf("StringIndex.Get() = %+v, %v, want entry 2", acct, err)
+	}
+	acct, err = accts.handle.Get(tx, CombinedKey{StringKey("x"), StringKey("a")})
+	if err != nil || acct.ID != 1 {
+		t.Errorf("CombinedIndex.Get() = %+v, %v, want entry 1", acct, err)
+	}
+	if _, err := accts.email.Get(tx, "c@example.com"); err != ErrNotFound {
+		t.Errorf("StringIndex.Get() error = %v, want %v", err, ErrNotFound)
+	}
+	if !accts.email.Unique() {
+		t.Errorf("StringIndex.Unique() = false, want true")
+	}
+
+	_, users := makeTestUserDB(t, makeTestUsers()...)
+	if users.status.Unique() {
+		t.Errorf("IntIndex.Unique() = true, want false")
+	}
+}
+
+func TestTxn_Commit_unique(t *testing.T) {
+	db, accts := makeTestAccountDB(t)
+	tx1, tx2 := db.WriteTx(), db.WriteTx()
+	_ = accts.Set(tx1, &testAccount{ID: 1, Email: "a@example.com"})
+	_ = accts.Set(tx2, &testAccount{ID: 2, Email: "a@example.com", Handle: "b"})
+	if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("Txn.Commit() error = %v, want %v", err, ErrUniqueViolation)
+	}
+}
+
+func TestDB_ApplyChangeSet_unique(t *testing.T) {
+	db, accts := makeTestAccountDB(t)
+	tx := db.WriteTx()
+	_ = accts.Set(tx, &testAccount{ID: 1, Email: "a@example.com"})
+	cs := tx.Changes()
+	tx.Abort()
+	tx = db.WriteTx()
+	_ = accts.Set(tx, &testAccount{ID: 2, Email: "a@example.com", Handle: "b"})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.ApplyChangeSet(cs); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("DB.ApplyChangeSet() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	if _, err := accts.Get(db.ReadTx(), IntKey(1)); err != ErrNotFound {
+		t.Errorf("Table.Get() of rejected entry error = %v, want %v", err, ErrNotFound)
+	}
+}
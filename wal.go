@@ -0,0 +1,282 @@
+package memdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the log to disk before each commit is
+	// published.
+	SyncAlways SyncPolicy = iota
+	// SyncInterval flushes the log to disk periodically, so commits
+	// made since the last flush may be lost on a crash of the system.
+	SyncInterval
+	// SyncNever leaves flushing the log to the operating system.
+	SyncNever
+)
+
+const DefaultSyncInterval = 100 * time.Millisecond
+
+type WALOptions struct {
+	// Path is the path of the log file, created if it does not exist.
+	Path string
+	// Snapshot is the path of a file written with DB.Save, loaded
+	// before the log is replayed. It is ignored if it does not exist,
+	// or if a checkpoint has been loaded.
+	Snapshot string
+	// Sync decides when the log is flushed to disk.
+	Sync SyncPolicy
+	// SyncInterval is the period of flushing with SyncInterval
+	// policy. Zero means DefaultSyncInterval.
+	SyncInterval time.Duration
+}
+
+// Records of the log are change sets encoded with EncodeChangeSet.
+const walHeaderSize = changeSetHeaderSize
+
+// wal is the append-only log of commits.
+type wal struct {
+	mu     sync.Mutex // guards f against periodic flushing
+	path   string
+	f      *os.File
+	size   int64
+	policy SyncPolicy
+	dirty  int32
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// openWAL loads the snapshot and replays the log on top of it, and
+// opens the log for appending commits.
+func (db *DB) openWAL(opts WALOptions) error {
+	// a loaded checkpoint is newer than the snapshot, which would
+	// take the database back to an older version
+	if opts.Snapshot != "" && db.Version() == 0 {
+		if err := db.loadFile(opts.Snapshot); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	f, err := os.OpenFile(opts.Path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	size, err := db.replay(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w := &wal{
+		path:   opts.Path,
+		f:      f,
+		size:   size,
+		policy: opts.Sync,
+		done:   make(chan struct{}),
+	}
+	if w.policy == SyncInterval {
+		interval := opts.SyncInterval
+		if interval <= 0 {
+			interval = DefaultSyncInterval
+		}
+		w.wg.Add(1)
+		go w.syncEvery(interval)
+	}
+	db.wal = w
+	return nil
+}
+
+// loadFile loads the snapshot stored in the file.
+func (db *DB) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return db.Load(f)
+}
+
+// replay applies commits of the log which are newer than the current
+// version of the database. The log is truncated after the last
+// complete record, so a record torn by a crash is discarded. It
+// returns the size of the log.
+func (db *DB) replay(f *os.File) (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	var offset int64
+	header := make([]byte, walHeaderSize)
+	for {
+		if _, err := io.ReadFull(f, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return 0, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		if offset+walHeaderSize+size > info.Size() {
+			break
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			return 0, err
+		}
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:]) {
+			break
+		}
+		cs, err := db.DecodeChangeSet(append(header, payload...))
+		if err != nil {
+			return 0, err
+		}
+		// commits loading snapshots may skip versions
+		if err := db.ApplyChangeSetWithOptions(cs, ApplyOptions{AllowGaps: true}); err != nil {
+			return 0, err
+		}
+		offset += walHeaderSize + size
+	}
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	return offset, err
+}
+
+// append writes the record to the log. It must be called with the
+// commit lock held.
+func (w *wal) append(rec []byte) error {
+	if w.closed {
+		return ErrClosed
+	}
+	if _, err := w.f.Write(rec); err != nil {
+		// drop the partially written record, so the following ones
+		// are not lost behind it
+		w.discard()
+		return err
+	}
+	switch w.policy {
+	case SyncAlways:
+		if err := w.f.Sync(); err != nil {
+			// the commit fails, so it must not be replayed
+			w.discard()
+			return err
+		}
+	case SyncInterval:
+		atomic.StoreInt32(&w.dirty, 1)
+	}
+	w.size += int64(len(rec))
+	return nil
+}
+
+// discard truncates the log back to the end of the last appended
+// record. If that fails as well, the log is closed, so following
+// commits fail instead of being appended after the discarded record.
+func (w *wal) discard() {
+	if w.f.Truncate(w.size) == nil {
+		if _, err := w.f.Seek(w.size, io.SeekStart); err == nil {
+			return
+		}
+	}
+	_ = w.close()
+}
+
+func (w *wal) syncEvery(interval time.Duration) {
+	defer w.wg.Done()
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if atomic.CompareAndSwapInt32(&w.dirty, 1, 0) {
+				w.mu.Lock()
+				_ = w.f.Sync()
+				w.mu.Unlock()
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// close stops the periodic flushing, flushes the log and closes it.
+// It must be called with the commit lock held.
+func (w *wal) close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	w.wg.Wait()
+	if err := w.f.Sync(); err != nil {
+		w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// trimWAL removes records of commits up to given version from the
+// log, once they are included in a checkpoint.
+func (db *DB) trimWAL(version uint64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.wal == nil || db.wal.closed {
+		return nil
+	}
+	return db.wal.trim(version)
+}
+
+// trim rewrites the log without the records of commits up to given
+// version. The rest of the log is copied to a temporary file which
+// replaces the log, so a crash leaves either the old or the new one.
+// It must be called with the commit lock held.
+func (w *wal) trim(version uint64) error {
+	var offset int64
+	header := make([]byte, walHeaderSize+8)
+	for offset < w.size {
+		if _, err := w.f.ReadAt(header, offset); err != nil {
+			return err
+		}
+		if binary.BigEndian.Uint64(header[walHeaderSize:]) > version {
+			break
+		}
+		offset += walHeaderSize + int64(binary.BigEndian.Uint32(header[:4]))
+	}
+	if offset == 0 {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(w.path), filepath.Base(w.path)+"*.tmp")
+	if err != nil {
+		return err
+	}
+	err = tmp.Chmod(0o644)
+	if err == nil {
+		_, err = io.Copy(tmp, io.NewSectionReader(w.f, offset, w.size-offset))
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), w.path)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	syncDir(filepath.Dir(w.path))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.f.Close()
+	w.f = tmp
+	w.size -= offset
+	return nil
+}
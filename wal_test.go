@@ -0,0 +1,164 @@
+package memdb
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDB_WAL(t *testing.T) {
+	dir := t.TempDir()
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		opts := WALOptions{Path: filepath.Join(dir, "wal"), Sync: policy}
+		db, users := openTestUserDB(t, Options{WAL: &opts})
+		tx := db.WriteTx()
+		_ = users.SetMulti(tx, makeTestUsers())
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		tx = db.WriteTx()
+		_ = users.Del(tx, IntKey(1))
+		_ = users.Set(tx, &testUser{ID: 2, Name: "Anna Smith"})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		version := db.Version()
+		if err := db.Close(); err != nil {
+			t.Fatal(err)
+		}
+		tx = db.WriteTx()
+		_ = users.Del(tx, IntKey(3))
+		if err := tx.Commit(); err != ErrClosed {
+			t.Errorf("Txn.Commit() after Close error = %v, want %v", err, ErrClosed)
+		}
+
+		db, users = openTestUserDB(t, Options{WAL: &opts})
+		if db.Version() != version {
+			t.Errorf("DB.Version() after replay = %v, want %v", db.Version(), version)
+		}
+		list, err := users.Select(db.ReadTx()).Where(users.name.Is("Anna Smith")).All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := testUserIDs(list); !reflect.DeepEqual(got, []int{2}) {
+			t.Errorf("TableLister.All() after replay = %v, want %v", got, []int{2})
+		}
+		list, err = users.Select(db.ReadTx()).All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := testUserIDs(list); !reflect.DeepEqual(got, []int{2, 3, 4, 5}) {
+			t.Errorf("TableLister.All() after replay = %v, want %v", got, []int{2, 3, 4, 5})
+		}
+		_ = db.Close()
+		_ = os.Remove(opts.Path)
+	}
+}
+
+func TestDB_WAL_tornTail(t *testing.T) {
+	opts := WALOptions{Path: filepath.Join(t.TempDir(), "wal"), Sync: SyncNever}
+	db, users := openTestUserDB(t, Options{WAL: &opts})
+	for _, usr := range makeTestUsers()[:2] {
+		tx := db.WriteTx()
+		_ = users.Set(tx, usr)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = db.Close()
+	info, err := os.Stat(opts.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// cut the last record in half
+	if err := os.Truncate(opts.Path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	db, users = openTestUserDB(t, Options{WAL: &opts})
+	list, _ := users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("TableLister.All() after replay = %v, want %v", got, []int{1})
+	}
+	tx := db.WriteTx()
+	_ = users.Set(tx, makeTestUsers()[2])
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+
+	db, users = openTestUserDB(t, Options{WAL: &opts})
+	defer db.Close()
+	list, _ = users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, []int{1, 3}) {
+		t.Errorf("TableLister.All() after replay = %v, want %v", got, []int{1, 3})
+	}
+}
+
+func TestDB_WAL_snapshot(t *testing.T) {
+	dir := t.TempDir()
+	opts := WALOptions{
+		Path:     filepath.Join(dir, "wal"),
+		Snapshot: filepath.Join(dir, "snapshot"),
+	}
+	db, users := openTestUserDB(t, Options{WAL: &opts})
+	for _, usr := range makeTestUsers() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, usr)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if usr.ID == 3 {
+			f, err := os.Create(opts.Snapshot)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := db.Save(f); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+		}
+	}
+	version := db.Version()
+	_ = db.Close()
+
+	// the log is replayed on top of the snapshot, skipping commits
+	// included in it
+	db, users = openTestUserDB(t, Options{WAL: &opts})
+	defer db.Close()
+	if db.Version() != version {
+		t.Errorf("DB.Version() after replay = %v, want %v", db.Version(), version)
+	}
+	list, _ := users.Select(db.ReadTx()).All()
+	if got := testUserIDs(list); !reflect.DeepEqual(got, testUserIDs(makeTestUsers())) {
+		t.Errorf("TableLister.All() after replay = %v, want %v", got, testUserIDs(makeTestUsers()))
+	}
+}
+
+func TestDB_WAL_appendError(t *testing.T) {
+	opts := WALOptions{Path: filepath.Join(t.TempDir(), "wal")}
+	db, users := openTestUserDB(t, Options{WAL: &opts})
+	tx := db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 1})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	// the log can neither be written nor truncated
+	db.wal.f.Close()
+	tx = db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 2})
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Txn.Commit() error = nil")
+	}
+	tx = db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 3})
+	if err := tx.Commit(); err != ErrClosed {
+		t.Errorf("Txn.Commit() after failed append error = %v, want %v", err, ErrClosed)
+	}
+
+	db, users = openTestUserDB(t, Options{WAL: &opts})
+	if n, _ := users.Select(db.ReadTx()).Count(); n != 1 {
+		t.Errorf("TableLister.Count() after replay = %v, want 1", n)
+	}
+}
@@ -0,0 +1,261 @@
+package memdb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+type WatchPolicy int
+
+const (
+	// WatchQueue keeps change sets which do not fit into the buffer of
+	// the subscriber in a queue until they are received. Commits never
+	// wait for the subscriber. A subscriber whose queue exceeds its
+	// limit is closed, and Subscription.Err returns ErrLagging.
+	WatchQueue WatchPolicy = iota
+	// WatchDrop discards changes which do not fit into the buffer of
+	// the subscriber. The number of discarded change sets is reported
+	// by Subscription.Dropped.
+	WatchDrop
+	// WatchBlock makes Commit wait, after the commit is published and
+	// without blocking other commits, until the subscriber's buffer
+	// takes the change set. A subscriber committing transactions
+	// while receiving change sets needs a buffer for them.
+	WatchBlock
+)
+
+// DefaultWatchQueue is the limit of change sets queued for a
+// subscriber with WatchQueue policy.
+const DefaultWatchQueue = 1024
+
+type WatchOptions struct {
+	// Buffer is the capacity of the subscription channel.
+	Buffer int
+	// Policy decides what happens with changes when the buffer of
+	// the subscription is full.
+	Policy WatchPolicy
+	// Queue is the number of change sets queued for a subscriber with
+	// WatchQueue policy before it is closed for not keeping up. Zero
+	// means DefaultWatchQueue.
+	Queue int
+}
+
+// Subscription delivers change sets of committed transactions in
+// commit order.
+type Subscription struct {
+	db      *DB
+	ch      chan *ChangeSet
+	refs    map[interface{}]struct{}
+	policy  WatchPolicy
+	limit   int
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+
+	mu     sync.Mutex // guards the fields below
+	queue  []*ChangeSet
+	ready  chan struct{}
+	sent   *sync.Cond // signalled when a change set is sent
+	queued uint64     // version of the last queued change set
+	last   uint64     // version of the last sent change set
+	closed bool
+	err    error
+}
+
+// Watch subscribes to changes of given tables, or of all the tables
+// if none is given, using an unbuffered channel and queue policy.
+func (db *DB) Watch(tables ...TableType) *Subscription {
+	return db.WatchWithOptions(WatchOptions{}, tables...)
+}
+
+// WatchWithOptions subscribes to changes of given tables, or of all
+// the tables if none is given. Change sets are delivered outside of
+// commits, so subscribers can commit transactions while receiving
+// them.
+func (db *DB) WatchWithOptions(opts WatchOptions, tables ...TableType) *Subscription {
+	if opts.Queue <= 0 {
+		opts.Queue = DefaultWatchQueue
+	}
+	s := &Subscription{
+		db:     db,
+		ch:     make(chan *ChangeSet, opts.Buffer),
+		policy: opts.Policy,
+		limit:  opts.Queue,
+		done:   make(chan struct{}),
+		ready:  make(chan struct{}, 1),
+	}
+	s.sent = sync.NewCond(&s.mu)
+	if len(tables) > 0 {
+		s.refs = make(map[interface{}]struct{}, len(tables))
+		for _, t := range tables {
+			s.refs[t.tableRef()] = struct{}{}
+		}
+	}
+	if s.policy != WatchDrop {
+		go s.deliver()
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.subs[s] = struct{}{}
+	return s
+}
+
+// C returns the channel delivering change sets. The channel is closed
+// when the subscription is closed.
+func (s *Subscription) C() <-chan *ChangeSet {
+	return s.ch
+}
+
+// Dropped returns the number of change sets discarded because the
+// buffer of the subscription was full.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err returns ErrLagging if the subscription was closed because its
+// queue exceeded the limit, and nil otherwise.
+func (s *Subscription) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Close stops the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.db.mu.Lock()
+	delete(s.db.subs, s)
+	s.db.mu.Unlock()
+	s.stop(nil)
+}
+
+// stop closes the subscription, which must have been removed from
+// subscriptions of the database.
+func (s *Subscription) stop(err error) {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.err = err
+		s.queue = nil
+		s.sent.Broadcast()
+		s.mu.Unlock()
+		close(s.done)
+		if s.policy == WatchDrop {
+			// the channel of other subscriptions is closed by the
+			// delivering goroutine
+			close(s.ch)
+		}
+	})
+}
+
+// wait blocks until change sets up to given version are sent to the
+// channel of a subscription with WatchBlock policy, or until it is
+// closed.
+func (s *Subscription) wait(version uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for !s.closed && s.last < version && s.last < s.queued {
+		s.sent.Wait()
+	}
+}
+
+// publish delivers changes of the root to subscribers.
+// It must be called with the commit lock held.
+func (db *DB) publish(root *dbRoot) {
+	if len(db.subs) == 0 {
+		return
+	}
+	all := make(map[interface{}][]Change, len(root.changes))
+	for ref, changes := range root.changes {
+		all[ref] = exportChanges(changes)
+	}
+	for s := range db.subs {
+		cs := &ChangeSet{Version: root.version, tables: all}
+		if s.refs != nil {
+			cs.tables = make(map[interface{}][]Change, len(s.refs))
+			for ref := range s.refs {
+				if changes, ok := all[ref]; ok {
+					cs.tables[ref] = changes
+				}
+			}
+			if len(cs.tables) == 0 {
+				continue
+			}
+		}
+		s.send(cs)
+	}
+}
+
+// send passes the change set to the subscriber without waiting for
+// it. It must be called with the commit lock held.
+func (s *Subscription) send(cs *ChangeSet) {
+	if s.policy == WatchDrop {
+		select {
+		case s.ch <- cs:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+		return
+	}
+	s.mu.Lock()
+	if s.policy == WatchQueue && len(s.queue) >= s.limit {
+		s.mu.Unlock()
+		delete(s.db.subs, s)
+		s.stop(ErrLagging)
+		return
+	}
+	s.queue = append(s.queue, cs)
+	s.queued = cs.Version
+	s.mu.Unlock()
+	select {
+	case s.ready <- struct{}{}:
+	default:
+	}
+}
+
+// deliver sends queued change sets to the channel of the subscription
+// until it is closed.
+func (s *Subscription) deliver() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		if len(s.queue) == 0 {
+			s.mu.Unlock()
+			select {
+			case <-s.ready:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		cs := s.queue[0]
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		select {
+		case s.ch <- cs:
+		case <-s.done:
+			return
+		}
+		s.mu.Lock()
+		s.last = cs.Version
+		s.sent.Broadcast()
+		s.mu.Unlock()
+	}
+}
+
+// waitSubscribers blocks until subscribers with WatchBlock policy
+// have taken change sets up to given version. It must be called
+// without the commit lock held.
+func (db *DB) waitSubscribers(version uint64) {
+	db.mu.Lock()
+	var blocking []*Subscription
+	for s := range db.subs {
+		if s.policy == WatchBlock {
+			blocking = append(blocking, s)
+		}
+	}
+	db.mu.Unlock()
+	for _, s := range blocking {
+		s.wait(version)
+	}
+}
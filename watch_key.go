@@ -0,0 +1,136 @@
+package memdb
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// WatchKey returns a channel which is closed by the first commit
+// changing or deleting the entry under given primary key after the
+// snapshot of the transaction. If such commit has already been made,
+// the returned channel is closed. The returned cancel function stops
+// watching the key, after which the channel may never be closed; it
+// should be called once the channel is no longer needed, as keys which
+// do not change are watched until then.
+func (t Table[V]) WatchKey(tx *Txn, id Key) (<-chan struct{}, func(), error) {
+	if err := tx.check(false); err != nil {
+		return nil, nil, err
+	}
+	ch, cancel := tx.db.watchKey(tx.root, t.ref, id.Bytes())
+	return ch, cancel, nil
+}
+
+// keyWatch is a channel shared by watchers of a key.
+type keyWatch struct {
+	ch chan struct{}
+	n  int // number of watchers which have not cancelled
+}
+
+func (db *DB) watchKey(since *dbRoot, ref interface{}, k []byte) (<-chan struct{}, func()) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for c := since.next; c != nil; c = c.next {
+		if changes, ok := c.changes[ref]; ok {
+			if _, ok := changes.root.get(k); ok {
+				ch := make(chan struct{})
+				close(ch)
+				return ch, func() {}
+			}
+		}
+	}
+	keys, ok := db.keys[ref]
+	if !ok {
+		keys = map[string]*keyWatch{}
+		db.keys[ref] = keys
+	}
+	// watchers of the same key share the channel
+	w, ok := keys[string(k)]
+	if !ok {
+		w = &keyWatch{ch: make(chan struct{})}
+		keys[string(k)] = w
+	}
+	w.n++
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() { db.unwatchKey(ref, k, w) })
+	}
+}
+
+// unwatchKey removes a watcher of the key, dropping the channel once
+// it has no watchers.
+func (db *DB) unwatchKey(ref interface{}, k []byte, w *keyWatch) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	keys := db.keys[ref]
+	// the channel may have been closed and replaced already
+	if keys[string(k)] != w {
+		return
+	}
+	w.n--
+	if w.n > 0 {
+		return
+	}
+	delete(keys, string(k))
+	if len(keys) == 0 {
+		delete(db.keys, ref)
+	}
+}
+
+// notifyKeys closes channels watching keys changed by the root.
+// It must be called with the commit lock held.
+func (db *DB) notifyKeys(root *dbRoot) {
+	for ref, changes := range root.changes {
+		keys, ok := db.keys[ref]
+		if !ok {
+			continue
+		}
+		c := changes.txn(false).cursor()
+		ok = c.first()
+		for ok {
+			if w, has := keys[string(c.key())]; has {
+				close(w.ch)
+				delete(keys, string(c.key()))
+			}
+			ok = c.next()
+		}
+		if len(keys) == 0 {
+			delete(db.keys, ref)
+		}
+	}
+}
+
+// WatchSet waits for any of multiple watch channels.
+type WatchSet struct {
+	chans []<-chan struct{}
+}
+
+func NewWatchSet() *WatchSet {
+	return &WatchSet{}
+}
+
+// Add adds the channel to the set.
+func (ws *WatchSet) Add(ch <-chan struct{}) {
+	ws.chans = append(ws.chans, ch)
+}
+
+// Wait blocks until any of the channels in the set is closed, or
+// until ctx is done, in which case its error is returned.
+func (ws *WatchSet) Wait(ctx context.Context) error {
+	cases := make([]reflect.SelectCase, 0, len(ws.chans)+1)
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(ctx.Done()),
+	})
+	for _, ch := range ws.chans {
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ch),
+		})
+	}
+	i, _, _ := reflect.Select(cases)
+	if i == 0 {
+		return ctx.Err()
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package memdb
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTable_WatchKey(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+	commit := func(fn func(tx *Txn) error) {
+		tx := db.WriteTx()
+		if err := fn(tx); err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := db.ReadTx()
+	tx := db.ReadTx()
+	ch1, _, err := users.WatchKey(tx, IntKey(1))
+	if err != nil {
+		t.Fatalf("Table.WatchKey() error = %v", err)
+	}
+	ch2, _, _ := users.WatchKey(tx, IntKey(2))
+	ch9, _, _ := users.WatchKey(tx, IntKey(9))
+
+	commit(func(tx *Txn) error {
+		return users.Set(tx, &testUser{ID: 1, Name: "Dave Smith"})
+	})
+	if !closed(ch1) || closed(ch2) || closed(ch9) {
+		t.Fatalf("watch channels closed = %v, %v, %v, want true, false, false", closed(ch1), closed(ch2), closed(ch9))
+	}
+	// the key has changed after the snapshot of the old transaction
+	if ch, _, _ := users.WatchKey(old, IntKey(1)); !closed(ch) {
+		t.Errorf("Table.WatchKey() for key changed since snapshot is not closed")
+	}
+
+	ws := NewWatchSet()
+	ws.Add(ch2)
+	ws.Add(ch9)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ws.Wait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("WatchSet.Wait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	go func() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 9})
+		_ = tx.Commit()
+	}()
+	if err := ws.Wait(context.Background()); err != nil {
+		t.Fatalf("WatchSet.Wait() error = %v", err)
+	}
+	if !closed(ch9) || closed(ch2) {
+		t.Errorf("watch channels closed = %v, %v, want true, false", closed(ch9), closed(ch2))
+	}
+	commit(func(tx *Txn) error {
+		return users.Del(tx, IntKey(2))
+	})
+	if !closed(ch2) {
+		t.Errorf("watch channel of deleted key is not closed")
+	}
+}
+
+func TestTable_WatchKey_cancel(t *testing.T) {
+	db, users := makeTestUserDB(t, makeTestUsers()...)
+	tx := db.ReadTx()
+	cancels := []func(){}
+	for i := 0; i < 1000; i++ {
+		_, cancel, err := users.WatchKey(tx, IntKey(100+i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cancels = append(cancels, cancel)
+	}
+	// the second watcher of a key keeps it watched
+	ch, cancel, _ := users.WatchKey(tx, IntKey(100))
+	for _, cancel := range cancels {
+		cancel()
+		cancel()
+	}
+	if n := len(db.keys[users.ref]); n != 1 {
+		t.Errorf("watched keys after cancel = %v, want 1", n)
+	}
+	wtx := db.WriteTx()
+	_ = users.Set(wtx, &testUser{ID: 100})
+	if err := wtx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+	default:
+		t.Errorf("watch channel of changed key is not closed")
+	}
+	cancel()
+	if n := len(db.keys); n != 0 {
+		t.Errorf("watched tables = %v, want 0", n)
+	}
+}
@@ -0,0 +1,204 @@
+package memdb
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDB_Watch(t *testing.T) {
+	users, backup := makeTestUserTable(), makeTestUserTable()
+	db, err := Init(users, backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub := db.WatchWithOptions(WatchOptions{Buffer: 10}, users)
+	defer sub.Close()
+
+	commit := func(fn func(tx *Txn)) {
+		tx := db.WriteTx()
+		fn(tx)
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anna := &testUser{ID: 1, Name: "Anna"}
+	bob := &testUser{ID: 2, Name: "Bob"}
+	anna2 := &testUser{ID: 1, Name: "Anna Smith"}
+	commit(func(tx *Txn) {
+		_ = users.SetMulti(tx, []*testUser{anna, bob})
+	})
+	commit(func(tx *Txn) {
+		_ = backup.Set(tx, anna)
+	})
+	commit(func(tx *Txn) {
+		_ = users.Set(tx, anna2)
+		_ = users.Del(tx, IntKey(2))
+		_ = backup.Set(tx, bob)
+	})
+
+	want := []struct {
+		version uint64
+		changes []Change
+	}{
+		{
+			version: 1,
+			changes: []Change{
+				{Kind: ChangeInsert, Key: IntKey(1).Bytes(), Value: anna},
+				{Kind: ChangeInsert, Key: IntKey(2).Bytes(), Value: bob},
+			},
+		},
+		{
+			version: 3,
+			changes: []Change{
+				{Kind: ChangeUpdate, Key: IntKey(1).Bytes(), Prev: anna, Value: anna2},
+				{Kind: ChangeDelete, Key: IntKey(2).Bytes(), Prev: bob},
+			},
+		},
+	}
+	for _, w := range want {
+		cs := <-sub.C()
+		if cs.Version != w.version {
+			t.Errorf("ChangeSet.Version = %v, want %v", cs.Version, w.version)
+		}
+		if got := cs.Changes(users); !reflect.DeepEqual(got, w.changes) {
+			t.Errorf("ChangeSet.Changes() = %+v, want %+v", got, w.changes)
+		}
+		if got := cs.Changes(backup); got != nil {
+			t.Errorf("ChangeSet.Changes() of not watched table = %+v, want nil", got)
+		}
+	}
+	sub.Close()
+	if _, ok := <-sub.C(); ok {
+		t.Errorf("Subscription.C() is not closed")
+	}
+}
+
+func TestDB_Watch_policy(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	drop := db.WatchWithOptions(WatchOptions{Buffer: 1, Policy: WatchDrop})
+	defer drop.Close()
+	queue := db.Watch()
+	received := make(chan uint64)
+	go func() {
+		for cs := range queue.C() {
+			received <- cs.Version
+		}
+		close(received)
+	}()
+	for i := 1; i <= 3; i++ {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+		if v := <-received; v != uint64(i) {
+			t.Errorf("ChangeSet.Version = %v, want %v", v, i)
+		}
+	}
+	queue.Close()
+	if _, ok := <-received; ok {
+		t.Errorf("Subscription.C() is not closed")
+	}
+	if cs := <-drop.C(); cs.Version != 1 {
+		t.Errorf("ChangeSet.Version = %v, want 1", cs.Version)
+	}
+	if n := drop.Dropped(); n != 2 {
+		t.Errorf("Subscription.Dropped() = %v, want 2", n)
+	}
+}
+
+func TestDB_Watch_commitFromSubscriber(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	sub := db.Watch(users)
+	defer sub.Close()
+	tx := db.WriteTx()
+	_ = users.Set(tx, &testUser{ID: 1})
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for cs := range sub.C() {
+			if cs.Version >= 3 {
+				return
+			}
+			// commit while the subscriber is not receiving
+			tx := db.WriteTx()
+			_ = users.Set(tx, &testUser{ID: 1, Status: int(cs.Version)})
+			if err := tx.Commit(); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("commit from the subscriber did not finish")
+	}
+}
+
+func TestDB_Watch_lagging(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	sub := db.WatchWithOptions(WatchOptions{Queue: 2})
+	defer sub.Close()
+	for i := 1; i <= 4; i++ {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: i})
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range sub.C() {
+	}
+	if err := sub.Err(); err != ErrLagging {
+		t.Errorf("Subscription.Err() = %v, want %v", err, ErrLagging)
+	}
+}
+
+func TestDB_Watch_block(t *testing.T) {
+	db, users := makeTestUserDB(t)
+	sub := db.WatchWithOptions(WatchOptions{Policy: WatchBlock})
+	defer sub.Close()
+	committed := make(chan error)
+	go func() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 1})
+		committed <- tx.Commit()
+	}()
+	select {
+	case <-committed:
+		t.Fatal("Txn.Commit() returned before the subscriber received the change set")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the commit is published while it waits for the subscriber
+	if v := db.Version(); v != 1 {
+		t.Errorf("DB.Version() = %v, want 1", v)
+	}
+	if cs := <-sub.C(); cs.Version != 1 {
+		t.Errorf("ChangeSet.Version = %v, want 1", cs.Version)
+	}
+	select {
+	case err := <-committed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Txn.Commit() did not return after the change set was received")
+	}
+	// closing the subscription releases waiting commits
+	go func() {
+		tx := db.WriteTx()
+		_ = users.Set(tx, &testUser{ID: 2})
+		committed <- tx.Commit()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sub.Close()
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Fatal("Txn.Commit() did not return after the subscription was closed")
+	}
+}